@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maximoAcompanantes = 20
+
+// Acompanantes son los acompañantes de un invitado. Cupo es cuántos puede
+// nombrar en total: los suyos más los que su familia todavía tiene libres.
+type Acompanantes struct {
+	Cupo    int      `json:"cupo"`
+	Nombres []string `json:"nombres"`
+}
+
+// listaNombres acepta una lista o un solo texto, porque json-enc manda un
+// texto cuando el formulario tiene un único campo nombres.
+type listaNombres []string
+
+func (l *listaNombres) UnmarshalJSON(datos []byte) error {
+	var nombre string
+	if err := json.Unmarshal(datos, &nombre); err == nil {
+		*l = listaNombres{nombre}
+		return nil
+	}
+	var nombres []string
+	if err := json.Unmarshal(datos, &nombres); err != nil {
+		return errors.New("nombres debe ser una lista de textos")
+	}
+	*l = nombres
+	return nil
+}
+
+type AcompanantesCommand struct {
+	Invitado_Id string       `json:"invitado_id"`
+	Nombres     listaNombres `json:"nombres"`
+}
+
+// normalizar quita espacios y descarta los campos vacíos del formulario.
+func (cmd *AcompanantesCommand) normalizar() {
+	nombres := listaNombres{}
+	for _, nombre := range cmd.Nombres {
+		if nombre = strings.TrimSpace(nombre); nombre != "" {
+			nombres = append(nombres, nombre)
+		}
+	}
+	cmd.Nombres = nombres
+}
+
+func (cmd AcompanantesCommand) validar() error {
+	for _, nombre := range cmd.Nombres {
+		if utf8.RuneCountInString(nombre) > largoMaximoNombre {
+			return fmt.Errorf("el nombre de un acompañante no puede superar %v caracteres", largoMaximoNombre)
+		}
+	}
+	return nil
+}
+
+type CupoCommand struct {
+	Cupo int `json:"cupo"`
+}
+
+func (cmd CupoCommand) validar() error {
+	if cmd.Cupo < 0 || cmd.Cupo > maximoAcompanantes {
+		return fmt.Errorf("el cupo debe estar entre 0 y %v", maximoAcompanantes)
+	}
+	return nil
+}
+
+// acompanantesVista son los datos de la plantilla acompanantes.
+type acompanantesVista struct {
+	Token        string
+	Acompanantes Acompanantes
+	Aviso        string
+}
+
+// Campos son los nombres ya guardados seguidos de campos vacíos hasta el cupo.
+func (v acompanantesVista) Campos() []string {
+	campos := append([]string{}, v.Acompanantes.Nombres...)
+	for len(campos) < v.Acompanantes.Cupo {
+		campos = append(campos, "")
+	}
+	return campos
+}
+
+// nombrarAcompanantes recibe la lista completa de acompañantes del invitado
+// del token: los que ya no vienen se borran. Después del cierre del plazo no
+// se aceptan cambios.
+func (s *servidor) nombrarAcompanantes(gc *gin.Context) {
+	var cmd AcompanantesCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	invitacion, err := s.resolverInvitacion(gc, cmd.Invitado_Id, alcanceRsvp, invitacionInvitado)
+	if err != nil {
+		s.responderError(gc, errorEnlace(err))
+		return
+	}
+
+	vista := acompanantesVista{Token: invitacion.Token, Acompanantes: Acompanantes{Nombres: cmd.Nombres}}
+
+	plazo, err := s.plazoDeInvitado(gc.Request.Context(), invitacion.Id_text, nil)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	if plazo.Cerrado {
+		vencido := plazoVencido(plazo)
+		vista.Aviso = vencido.Mensaje
+		s.responderFormulario(gc, vencido, "acompanantes", vista)
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		vista.Aviso = err.Error()
+		s.responderFormulario(gc, entradaInvalida(err.Error()), "acompanantes", vista)
+		return
+	}
+
+	acompanantes, err := s.invitados.NombrarAcompanantes(gc.Request.Context(), invitacion.Id_text, cmd.Nombres)
+	if err != nil {
+		err = errorAcompanantes(err, acompanantes.Cupo)
+		vista.Acompanantes.Cupo = acompanantes.Cupo
+		vista.Aviso = "Error, intentalo de nuevo"
+		var e *errorApi
+		if errors.As(err, &e) {
+			vista.Aviso = e.Mensaje
+		}
+		s.responderFormulario(gc, err, "acompanantes", vista)
+		return
+	}
+
+	vista.Acompanantes = acompanantes
+	vista.Aviso = "¡Listo! Guardamos a tus acompañantes"
+	s.responder(gc, http.StatusOK, acompanantes, "acompanantes", vista)
+}
+
+func (s *servidor) cambiarCupoInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd CupoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	invitado, err := s.invitados.CambiarCupoInvitado(gc.Request.Context(), id, cmd.Cupo)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitado)
+}
+
+func (s *servidor) cambiarCupoFamilia(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd CupoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	familia, err := s.familias.CambiarCupoFamilia(gc.Request.Context(), id, cmd.Cupo)
+	if err != nil {
+		s.responderError(gc, errorFamilia(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, familia)
+}
+
+// errorAcompanantes traduce los errores al nombrar acompañantes; cupo es el
+// que devolvió el store.
+func errorAcompanantes(err error, cupo int) error {
+	switch {
+	case errors.Is(err, errAnfitrionNoAsiste):
+		return conflicto("Confirma tu asistencia antes de nombrar a tus acompañantes")
+	case errors.Is(err, errInvitadoEsAcompanante):
+		return conflicto("Un acompañante no puede nombrar acompañantes")
+	case errors.Is(err, errCupoAcompanantesExcedido):
+		return &errorApi{
+			Tipo:     tipoNoProcesable,
+			Mensaje:  fmt.Sprintf("Solo puedes nombrar %v acompañantes", cupo),
+			Detalles: gin.H{"cupo": cupo},
+		}
+	case errors.Is(err, errInvitadoNoEncontrado):
+		return errorEnlace(err)
+	default:
+		return err
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const prefijoApiV1 = "/api/v1"
+
+const claveApiV1Ctx = "apiV1"
+
+// InvitadoV1 es un invitado en /api/v1. La gestión recibe id e id_text; un
+// invitado que entra con su enlace recibe en cambio el token con el que
+// responde.
+type InvitadoV1 struct {
+	Id                int64  `json:"id,omitempty"`
+	Id_text           string `json:"id_text,omitempty"`
+	Token             string `json:"token,omitempty"`
+	Nombre            string `json:"nombre"`
+	Nombre_invitacion string `json:"nombre_invitacion"`
+	// Asiste es null mientras el invitado no responde.
+	Asiste            *bool              `json:"asiste"`
+	Eventos           []AsistenciaEvento `json:"eventos,omitempty"`
+	Cupo_acompanantes int                `json:"cupo_acompanantes,omitempty"`
+	// Anfitrion es el id_text de quien nombró al acompañante.
+	Anfitrion    *string       `json:"anfitrion,omitempty"`
+	Acompanantes *Acompanantes `json:"acompanantes,omitempty"`
+	Mesa         string        `json:"mesa,omitempty"`
+	Menu         *EleccionMenu `json:"menu,omitempty"`
+	// Prorroga la ve la gestión; Plazo, el invitado.
+	Prorroga *time.Time   `json:"prorroga,omitempty"`
+	Plazo    *estadoPlazo `json:"plazo,omitempty"`
+}
+
+// FamiliaV1 es una familia en /api/v1. Invitados solo viene cuando la pide un
+// invitado con el enlace de la familia.
+type FamiliaV1 struct {
+	Id                int64        `json:"id,omitempty"`
+	Id_text           string       `json:"id_text,omitempty"`
+	Nombre            string       `json:"nombre"`
+	Nombre_invitacion string       `json:"nombre_invitacion"`
+	Miembro_principal int64        `json:"miembro_principal,omitempty"`
+	Cupo_acompanantes int          `json:"cupo_acompanantes,omitempty"`
+	Invitados         []InvitadoV1 `json:"invitados,omitempty"`
+}
+
+func invitadoV1(invitado InvitadoResp) InvitadoV1 {
+	v1 := InvitadoV1{Id: invitado.Id, Id_text: invitado.Id_text, Nombre: invitado.Nombre, Nombre_invitacion: invitado.Nombre_invitacion, Cupo_acompanantes: invitado.Cupo_acompanantes, Anfitrion: invitado.Anfitrion, Mesa: invitado.Mesa, Prorroga: invitado.Prorroga}
+	if invitado.Asiste.Valid {
+		v1.Asiste = &invitado.Asiste.Bool
+	}
+	return v1
+}
+
+func invitadoPublicoV1(invitado InvitadoPublico) InvitadoV1 {
+	return InvitadoV1{Token: invitado.Token, Nombre: invitado.Nombre, Nombre_invitacion: invitado.Nombre_invitacion, Asiste: invitado.Asiste, Eventos: invitado.Eventos, Acompanantes: invitado.Acompanantes, Menu: invitado.Menu, Plazo: invitado.Plazo}
+}
+
+func familiaV1(familia FamiliasResp) FamiliaV1 {
+	return FamiliaV1{Id: familia.Id, Id_text: familia.Id_text, Nombre: familia.Nombre, Nombre_invitacion: familia.Nombre_invitacion, Miembro_principal: familia.Miembro_principal, Cupo_acompanantes: familia.Cupo_acompanantes}
+}
+
+// aV1 convierte las respuestas que todavía usan los nombres de Go a sus DTO
+// de /api/v1. El resto ya tiene etiquetas json y pasa igual.
+func aV1(datos any) any {
+	switch d := datos.(type) {
+	case InvitadoResp:
+		return invitadoV1(d)
+	case []InvitadoResp:
+		lista := make([]InvitadoV1, 0, len(d))
+		for _, invitado := range d {
+			lista = append(lista, invitadoV1(invitado))
+		}
+		return lista
+	case InvitadoPublico:
+		return invitadoPublicoV1(d)
+	case FamiliasResp:
+		return familiaV1(d)
+	case []FamiliasResp:
+		lista := make([]FamiliaV1, 0, len(d))
+		for _, familia := range d {
+			lista = append(lista, familiaV1(familia))
+		}
+		return lista
+	case FamiliaPublica:
+		v1 := FamiliaV1{Nombre: d.Nombre, Nombre_invitacion: d.Nombre_invitacion, Invitados: []InvitadoV1{}}
+		for _, invitado := range d.Invitados {
+			v1.Invitados = append(v1.Invitados, invitadoPublicoV1(invitado))
+		}
+		return v1
+	}
+	return datos
+}
+
+func esApiV1(gc *gin.Context) bool {
+	return gc.GetBool(claveApiV1Ctx)
+}
+
+func marcarApiV1(gc *gin.Context) {
+	gc.Set(claveApiV1Ctx, true)
+	gc.Next()
+}
+
+// json responde con datos; en /api/v1, con su DTO. Las rutas sin versión
+// conservan la forma de siempre para no romper a los clientes actuales.
+func (s *servidor) json(gc *gin.Context, status int, datos any) {
+	if esApiV1(gc) {
+		datos = aV1(datos)
+	}
+	gc.IndentedJSON(status, datos)
+}
+
+// rutasApiV1 describe /api/v1. La misma tabla registra las rutas y genera el
+// documento OpenAPI, así no se pueden separar.
+func (s *servidor) rutasApiV1() []rutaApi {
+	gestion := s.requiereRol(rolesGestion...)
+	lectura := s.requiereRol(rolesLectura...)
+	soloPareja := s.requiereRol(rolPareja)
+
+	consulta := s.limitar("consulta", s.limites.PorToken)
+	respuesta := s.limitar("respuesta", s.limites.PorToken)
+	escritura := s.limitar("escritura", s.limites.Escritura)
+
+	return []rutaApi{
+		{Metodo: http.MethodPost, Ruta: "/sesion", Resumen: "Inicia sesión y deja la cookie de sesión", Acceso: accesoPublico,
+			Peticion: LoginRequest{}, Respuesta: Usuario{}, Handlers: []gin.HandlerFunc{s.iniciarSesion}},
+		{Metodo: http.MethodDelete, Ruta: "/sesion", Resumen: "Cierra la sesión", Acceso: accesoPublico,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{s.cerrarSesion}},
+		{Metodo: http.MethodGet, Ruta: "/yo", Resumen: "Usuario de la sesión o del token", Acceso: accesoLectura,
+			Respuesta: Usuario{}, Handlers: []gin.HandlerFunc{lectura, s.getUsuarioActual}},
+		{Metodo: http.MethodGet, Ruta: "/usuarios", Resumen: "Lista los usuarios", Acceso: accesoPareja,
+			Respuesta: []Usuario{}, Handlers: []gin.HandlerFunc{soloPareja, s.getUsuarios}},
+		{Metodo: http.MethodPost, Ruta: "/usuarios", Resumen: "Crea un usuario", Acceso: accesoPareja,
+			Peticion: UsuarioCommand{}, Respuesta: Usuario{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{soloPareja, s.crearUsuario}},
+		{Metodo: http.MethodPost, Ruta: "/tokens", Resumen: "Crea un token de API con el rol del usuario", Acceso: accesoLectura,
+			Peticion: TokenApiCommand{}, Respuesta: TokenApiResp{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{lectura, s.crearTokenApiPropio}},
+		{Metodo: http.MethodDelete, Ruta: "/tokens/:id", Resumen: "Revoca un token de API propio", Acceso: accesoLectura,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{lectura, s.revocarTokenApi}},
+
+		{Metodo: http.MethodGet, Ruta: "/invitados", Resumen: "Lista los invitados", Acceso: accesoGestion,
+			Respuesta: []InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.getInvitados}},
+		{Metodo: http.MethodPost, Ruta: "/invitados", Resumen: "Crea un invitado", Acceso: accesoGestion,
+			Peticion: InvitadoCommand{}, Respuesta: InvitadoV1{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.crearInvitado}},
+		{Metodo: http.MethodGet, Ruta: "/invitados/:id", Resumen: "Un invitado, por id_text para la gestión o por el token de su enlace", Acceso: accesoGestionOInvitado,
+			Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{s.gestionOInvitado(consulta), s.requiereAlcance(""), s.getInvitado}},
+		{Metodo: http.MethodPut, Ruta: "/invitados/:id", Resumen: "Reemplaza un invitado; sin asiste queda sin respuesta", Acceso: accesoGestion,
+			Peticion: InvitadoCommand{}, Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.reemplazarInvitado}},
+		{Metodo: http.MethodPatch, Ruta: "/invitados/:id", Resumen: "Cambia solo los campos enviados de un invitado", Acceso: accesoGestion,
+			Peticion: InvitadoCommand{}, Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.modificarInvitado}},
+		{Metodo: http.MethodDelete, Ruta: "/invitados/:id", Resumen: "Elimina un invitado", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.eliminarInvitado}},
+		{Metodo: http.MethodGet, Ruta: "/invitados/:id/invitacion", Resumen: "Token vigente del invitado; lo emite si no tenía", Acceso: accesoGestion,
+			Respuesta: Invitacion{}, Handlers: []gin.HandlerFunc{gestion, s.getInvitacion(invitacionInvitado)}},
+		{Metodo: http.MethodPost, Ruta: "/invitados/:id/invitacion", Resumen: "Rota el token del invitado", Acceso: accesoGestion,
+			Peticion: InvitacionCommand{}, Respuesta: Invitacion{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.rotarInvitacion(invitacionInvitado)}},
+		{Metodo: http.MethodDelete, Ruta: "/invitados/:id/invitacion", Resumen: "Revoca el token del invitado", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.revocarInvitacion(invitacionInvitado)}},
+		{Metodo: http.MethodPost, Ruta: "/invitados/:id/enlace", Resumen: "Firma un enlace para el invitado", Acceso: accesoGestion,
+			Peticion: EnlaceCommand{}, Respuesta: EnlaceResp{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.crearEnlaceFirmado(invitacionInvitado)}},
+
+		{Metodo: http.MethodGet, Ruta: "/familias", Resumen: "Lista las familias", Acceso: accesoGestion,
+			Respuesta: []FamiliaV1{}, Handlers: []gin.HandlerFunc{gestion, s.getFamilias}},
+		{Metodo: http.MethodPost, Ruta: "/familias", Resumen: "Crea una familia", Acceso: accesoGestion,
+			Peticion: FamiliasCommand{}, Respuesta: FamiliaV1{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.crearFamilia}},
+		{Metodo: http.MethodGet, Ruta: "/familias/:id", Resumen: "Una familia, por id_text para la gestión o por el token de su enlace, con sus miembros", Acceso: accesoGestionOInvitado,
+			Respuesta: FamiliaV1{}, Handlers: []gin.HandlerFunc{s.gestionOInvitado(consulta), s.requiereAlcance(""), s.getFamilia}},
+		{Metodo: http.MethodPut, Ruta: "/familias/:id", Resumen: "Reemplaza una familia", Acceso: accesoGestion,
+			Peticion: FamiliasCommand{}, Respuesta: FamiliaV1{}, Handlers: []gin.HandlerFunc{gestion, s.reemplazarFamilia}},
+		{Metodo: http.MethodDelete, Ruta: "/familias/:id", Resumen: "Elimina una familia; con cascada=true también sus invitados", Acceso: accesoGestion,
+			Banderas: []string{"cascada"}, Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.eliminarFamilia}},
+		{Metodo: http.MethodGet, Ruta: "/familias/:id/invitados", Resumen: "Los miembros de una familia", Acceso: accesoGestion,
+			Respuesta: []InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.getInvitadoByFamiliaId}},
+		{Metodo: http.MethodPut, Ruta: "/familias/:id/invitados/:invitadoId", Resumen: "Mueve un invitado a la familia", Acceso: accesoGestion,
+			Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.agregarInvitadoAFamilia}},
+		{Metodo: http.MethodDelete, Ruta: "/familias/:id/invitados/:invitadoId", Resumen: "Saca a un invitado de la familia", Acceso: accesoGestion,
+			Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.quitarInvitadoDeFamilia}},
+		{Metodo: http.MethodGet, Ruta: "/familias/:id/invitacion", Resumen: "Token vigente de la familia; lo emite si no tenía", Acceso: accesoGestion,
+			Respuesta: Invitacion{}, Handlers: []gin.HandlerFunc{gestion, s.getInvitacion(invitacionFamilia)}},
+		{Metodo: http.MethodPost, Ruta: "/familias/:id/invitacion", Resumen: "Rota el token de la familia", Acceso: accesoGestion,
+			Peticion: InvitacionCommand{}, Respuesta: Invitacion{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.rotarInvitacion(invitacionFamilia)}},
+		{Metodo: http.MethodDelete, Ruta: "/familias/:id/invitacion", Resumen: "Revoca el token de la familia", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.revocarInvitacion(invitacionFamilia)}},
+		{Metodo: http.MethodPost, Ruta: "/familias/:id/enlace", Resumen: "Firma un enlace para la familia", Acceso: accesoGestion,
+			Peticion: EnlaceCommand{}, Respuesta: EnlaceResp{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.crearEnlaceFirmado(invitacionFamilia)}},
+		{Metodo: http.MethodGet, Ruta: "/invitados/:id/eventos", Resumen: "Los eventos del invitado con su respuesta a cada uno", Acceso: accesoGestion,
+			Respuesta: []AsistenciaEvento{}, Handlers: []gin.HandlerFunc{gestion, s.getEventosDeInvitado}},
+		{Metodo: http.MethodPut, Ruta: "/invitados/:id/cupo", Resumen: "Cuántos acompañantes puede traer el invitado", Acceso: accesoGestion,
+			Peticion: CupoCommand{}, Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.cambiarCupoInvitado}},
+		{Metodo: http.MethodPut, Ruta: "/invitados/:id/mesa", Resumen: "Asigna la mesa del invitado", Acceso: accesoGestion,
+			Peticion: MesaCommand{}, Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.cambiarMesa}},
+		{Metodo: http.MethodPut, Ruta: "/invitados/:id/prorroga", Resumen: "Deja responder al invitado hasta la fecha indicada, aunque haya pasado la fecha límite; null la quita", Acceso: accesoGestion,
+			Peticion: ProrrogaCommand{}, Respuesta: InvitadoV1{}, Handlers: []gin.HandlerFunc{gestion, s.cambiarProrroga}},
+		{Metodo: http.MethodGet, Ruta: "/invitados/:id/menu", Resumen: "El menú que eligió el invitado", Acceso: accesoGestion,
+			Respuesta: EleccionMenu{}, Handlers: []gin.HandlerFunc{gestion, s.getMenuInvitado}},
+		{Metodo: http.MethodPut, Ruta: "/invitados/:id/menu", Resumen: "Carga el menú de un invitado que aceptó", Acceso: accesoGestion,
+			Peticion: MenuCommand{}, Respuesta: EleccionMenu{}, Handlers: []gin.HandlerFunc{gestion, s.cambiarMenuInvitado}},
+		{Metodo: http.MethodPut, Ruta: "/familias/:id/cupo", Resumen: "Acompañantes que comparten los miembros de la familia", Acceso: accesoGestion,
+			Peticion: CupoCommand{}, Respuesta: FamiliaV1{}, Handlers: []gin.HandlerFunc{gestion, s.cambiarCupoFamilia}},
+		{Metodo: http.MethodGet, Ruta: "/invitados/:id/historial", Resumen: "Los cambios al invitado, del más viejo al más nuevo, aunque ya se haya eliminado", Acceso: accesoGestion,
+			Respuesta: []EntradaAuditoria{}, Handlers: []gin.HandlerFunc{gestion, s.getHistorialInvitado}},
+		{Metodo: http.MethodGet, Ruta: "/familias/:id/historial", Resumen: "Los cambios a la familia y a sus miembros mientras lo eran", Acceso: accesoGestion,
+			Respuesta: []EntradaAuditoria{}, Handlers: []gin.HandlerFunc{gestion, s.getHistorialFamilia}},
+
+		{Metodo: http.MethodGet, Ruta: "/eventos", Resumen: "Lista los eventos en orden", Acceso: accesoLectura,
+			Respuesta: []EventoResp{}, Handlers: []gin.HandlerFunc{lectura, s.getEventos}},
+		{Metodo: http.MethodPost, Ruta: "/eventos", Resumen: "Crea un evento", Acceso: accesoGestion,
+			Peticion: EventoCommand{}, Respuesta: EventoResp{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.crearEvento}},
+		{Metodo: http.MethodGet, Ruta: "/eventos/:id", Resumen: "Un evento", Acceso: accesoLectura,
+			Respuesta: EventoResp{}, Handlers: []gin.HandlerFunc{lectura, s.getEvento}},
+		{Metodo: http.MethodPut, Ruta: "/eventos/:id", Resumen: "Reemplaza un evento", Acceso: accesoGestion,
+			Peticion: EventoCommand{}, Respuesta: EventoResp{}, Handlers: []gin.HandlerFunc{gestion, s.reemplazarEvento}},
+		{Metodo: http.MethodDelete, Ruta: "/eventos/:id", Resumen: "Elimina un evento con sus invitaciones y respuestas", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.eliminarEvento}},
+		{Metodo: http.MethodGet, Ruta: "/eventos/:id/invitados", Resumen: "Los invitados al evento; asiste es su respuesta a este evento", Acceso: accesoLectura,
+			Respuesta: []InvitadoV1{}, Handlers: []gin.HandlerFunc{lectura, s.getInvitadosDeEvento}},
+		{Metodo: http.MethodPut, Ruta: "/eventos/:id/invitados/:invitadoId", Resumen: "Invita a un invitado al evento", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.invitarAEvento}},
+		{Metodo: http.MethodDelete, Ruta: "/eventos/:id/invitados/:invitadoId", Resumen: "Quita a un invitado del evento", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.quitarDeEvento}},
+		{Metodo: http.MethodGet, Ruta: "/eventos/:id/historial", Resumen: "Los cambios al evento, aunque ya se haya eliminado", Acceso: accesoGestion,
+			Respuesta: []EntradaAuditoria{}, Handlers: []gin.HandlerFunc{gestion, s.getHistorialEvento}},
+
+		{Metodo: http.MethodGet, Ruta: "/platillos", Resumen: "Las opciones del menú en orden", Acceso: accesoPublico,
+			Respuesta: []PlatilloResp{}, Handlers: []gin.HandlerFunc{consulta, s.getPlatillos}},
+		{Metodo: http.MethodPost, Ruta: "/platillos", Resumen: "Agrega una opción al menú", Acceso: accesoGestion,
+			Peticion: PlatilloCommand{}, Respuesta: PlatilloResp{}, Status: http.StatusCreated, Handlers: []gin.HandlerFunc{gestion, s.crearPlatillo}},
+		{Metodo: http.MethodGet, Ruta: "/platillos/:id", Resumen: "Una opción del menú", Acceso: accesoPublico,
+			Respuesta: PlatilloResp{}, Handlers: []gin.HandlerFunc{consulta, s.getPlatillo}},
+		{Metodo: http.MethodPut, Ruta: "/platillos/:id", Resumen: "Reemplaza una opción del menú", Acceso: accesoGestion,
+			Peticion: PlatilloCommand{}, Respuesta: PlatilloResp{}, Handlers: []gin.HandlerFunc{gestion, s.reemplazarPlatillo}},
+		{Metodo: http.MethodDelete, Ruta: "/platillos/:id", Resumen: "Quita una opción del menú; quienes la eligieron quedan sin elegir", Acceso: accesoGestion,
+			Status: http.StatusNoContent, Handlers: []gin.HandlerFunc{gestion, s.eliminarPlatillo}},
+		{Metodo: http.MethodGet, Ruta: "/platillos/:id/historial", Resumen: "Los cambios a la opción del menú, aunque ya se haya eliminado", Acceso: accesoGestion,
+			Respuesta: []EntradaAuditoria{}, Handlers: []gin.HandlerFunc{gestion, s.getHistorialPlatillo}},
+		{Metodo: http.MethodGet, Ruta: "/catering", Resumen: "Platillos y restricciones de los que aceptaron, con alergias por mesa", Acceso: accesoLectura,
+			Respuesta: ReporteCatering{}, Handlers: []gin.HandlerFunc{lectura, s.getReporteCatering}},
+
+		{Metodo: http.MethodGet, Ruta: "/invitaciones", Resumen: "Lista los tokens vigentes", Acceso: accesoGestion,
+			Respuesta: []Invitacion{}, Handlers: []gin.HandlerFunc{gestion, s.getInvitaciones}},
+		{Metodo: http.MethodGet, Ruta: "/rsvp", Resumen: "Totales y respuestas de todos los invitados", Acceso: accesoLectura,
+			Respuesta: tablaRsvpVista{}, Handlers: []gin.HandlerFunc{lectura, s.getTablaRsvp}},
+
+		{Metodo: http.MethodPost, Ruta: "/asistencia/aceptar", Resumen: "El invitado del token acepta", Acceso: accesoInvitado,
+			Peticion: InvitadoId{}, Respuesta: ResultadoAsistencia{}, Handlers: []gin.HandlerFunc{respuesta, s.requiereAlcance(alcanceRsvp), s.aceptarInvitacion}},
+		{Metodo: http.MethodPost, Ruta: "/asistencia/rechazar", Resumen: "El invitado del token rechaza", Acceso: accesoInvitado,
+			Peticion: InvitadoId{}, Respuesta: ResultadoAsistencia{}, Handlers: []gin.HandlerFunc{respuesta, s.requiereAlcance(alcanceRsvp), s.rechazarInvitacion}},
+		{Metodo: http.MethodPost, Ruta: "/eventos/:id/asistencia/aceptar", Resumen: "El invitado del token acepta el evento", Acceso: accesoInvitado,
+			Peticion: InvitadoId{}, Respuesta: ResultadoAsistencia{}, Handlers: []gin.HandlerFunc{respuesta, s.requiereAlcance(alcanceRsvp), s.aceptarEvento}},
+		{Metodo: http.MethodPost, Ruta: "/eventos/:id/asistencia/rechazar", Resumen: "El invitado del token rechaza el evento", Acceso: accesoInvitado,
+			Peticion: InvitadoId{}, Respuesta: ResultadoAsistencia{}, Handlers: []gin.HandlerFunc{respuesta, s.requiereAlcance(alcanceRsvp), s.rechazarEvento}},
+		{Metodo: http.MethodPost, Ruta: "/acompanantes", Resumen: "El invitado del token nombra a sus acompañantes; reemplaza la lista anterior", Acceso: accesoInvitado,
+			Peticion: AcompanantesCommand{}, Respuesta: Acompanantes{}, Handlers: []gin.HandlerFunc{respuesta, s.requiereAlcance(alcanceRsvp), s.nombrarAcompanantes}},
+		{Metodo: http.MethodPost, Ruta: "/menu", Resumen: "El invitado del token elige su menú; reemplaza la elección anterior", Acceso: accesoInvitado,
+			Peticion: MenuCommand{}, Respuesta: EleccionMenu{}, Handlers: []gin.HandlerFunc{respuesta, s.requiereAlcance(alcanceRsvp), s.elegirMenu}},
+		{Metodo: http.MethodPost, Ruta: "/asistencia/lote", Resumen: "Hasta 20 respuestas en una transacción; si un token no existe no se aplica ninguna y responde 404 sin decir cuál", Acceso: accesoInvitado,
+			Peticion: []Asistencia{}, Respuesta: RespuestaLote{}, Handlers: []gin.HandlerFunc{respuesta, s.updateMultiplesInvitadosAsistencia}},
+		{Metodo: http.MethodPost, Ruta: "/canciones", Resumen: "Sugiere una canción", Acceso: accesoInvitado,
+			Peticion: CancionRequest{}, Respuesta: RespuestaMensaje{}, Handlers: []gin.HandlerFunc{escritura, s.requiereAlcance(alcanceCancion), s.agregarCancion}},
+		{Metodo: http.MethodPost, Ruta: "/mensajes", Resumen: "Deja un mensaje para la pareja", Acceso: accesoInvitado,
+			Peticion: MensajeRequest{}, Respuesta: RespuestaMensaje{}, Handlers: []gin.HandlerFunc{escritura, s.requiereAlcance(alcanceMensaje), s.agregarMensaje}},
+	}
+}
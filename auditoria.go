@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Acciones del historial de cambios.
+const (
+	accionAsistencia        = "asistencia"
+	accionAsistenciaEvento  = "asistencia_evento"
+	accionAcompanantes      = "acompanantes"
+	accionMenu              = "menu"
+	accionCancion           = "cancion"
+	accionMensaje           = "mensaje"
+	accionCrearInvitado     = "crear_invitado"
+	accionEditarInvitado    = "editar_invitado"
+	accionEliminarInvitado  = "eliminar_invitado"
+	accionImportarInvitado  = "importar_invitado"
+	accionMoverInvitado     = "mover_invitado"
+	accionCupo              = "cupo"
+	accionProrroga          = "prorroga"
+	accionMesa              = "mesa"
+	accionInvitarAEvento    = "invitar_evento"
+	accionQuitarDeEvento    = "quitar_evento"
+	accionCrearFamilia      = "crear_familia"
+	accionEditarFamilia     = "editar_familia"
+	accionEliminarFamilia   = "eliminar_familia"
+	accionRotarInvitacion   = "rotar_invitacion"
+	accionRevocarInvitacion = "revocar_invitacion"
+	accionCrearEvento       = "crear_evento"
+	accionEditarEvento      = "editar_evento"
+	accionEliminarEvento    = "eliminar_evento"
+	accionCrearPlatillo     = "crear_platillo"
+	accionEditarPlatillo    = "editar_platillo"
+	accionEliminarPlatillo  = "eliminar_platillo"
+	accionCrearUsuario      = "crear_usuario"
+	accionCrearTokenApi     = "crear_token_api"
+	accionRevocarTokenApi   = "revocar_token_api"
+)
+
+// Recursos del historial que no son un invitado ni una familia.
+const (
+	recursoEvento   = "evento"
+	recursoPlatillo = "platillo"
+	recursoUsuario  = "usuario"
+	recursoTokenApi = "token_api"
+)
+
+// Tipos de autor de un cambio, además de invitacionInvitado e
+// invitacionFamilia cuando responde un invitado con su enlace.
+const (
+	autorUsuario = "usuario"
+	autorSistema = "sistema"
+)
+
+// maxUserAgent es el largo de la columna user_agent en MySQL.
+const maxUserAgent = 512
+
+// AutorAuditoria es quién hizo un cambio: el usuario de la gestión o la
+// invitación con la que respondió el invitado, y desde dónde. El token no se
+// guarda, porque con él se puede responder por el invitado; Huella permite
+// saber si dos cambios usaron el mismo enlace.
+type AutorAuditoria struct {
+	Tipo       string `json:"tipo"`
+	Nombre     string `json:"nombre,omitempty"`
+	Huella     string `json:"huella,omitempty"`
+	Ip         string `json:"ip,omitempty"`
+	User_agent string `json:"user_agent,omitempty"`
+}
+
+// EntradaAuditoria es una fila del historial. Recurso e Id_recurso son el
+// evento, platillo, usuario o token de API cambiado. Anterior y Nuevo son los
+// valores en JSON y faltan cuando no aplican, como Anterior al crear.
+type EntradaAuditoria struct {
+	Id         int64           `json:"id"`
+	Fecha      time.Time       `json:"fecha"`
+	Accion     string          `json:"accion"`
+	Invitado   string          `json:"invitado,omitempty"`
+	Familia    string          `json:"familia,omitempty"`
+	Recurso    string          `json:"recurso,omitempty"`
+	Id_recurso string          `json:"id_recurso,omitempty"`
+	Autor      AutorAuditoria  `json:"autor"`
+	Anterior   json.RawMessage `json:"anterior,omitempty"`
+	Nuevo      json.RawMessage `json:"nuevo,omitempty"`
+}
+
+// AsistenciaAuditada es el valor de una respuesta a un evento en el historial.
+type AsistenciaAuditada struct {
+	Evento string `json:"evento"`
+	Asiste *bool  `json:"asiste"`
+}
+
+// autorCambio es quién hace la petición. registrarAutor lo deja en el
+// contexto y lo completan requiereRol, con el usuario, y resolverInvitacion,
+// con cada invitación que resuelve; el store lo lee al escribir el historial.
+// Fuera de una petición, como en los comandos, no hay autor y los cambios
+// quedan como del sistema.
+type autorCambio struct {
+	ip           string
+	userAgent    string
+	usuario      *Usuario
+	invitaciones []Invitacion
+}
+
+type claveAutorCtx struct{}
+
+func (s *servidor) registrarAutor(gc *gin.Context) {
+	autor := &autorCambio{ip: gc.ClientIP(), userAgent: gc.Request.UserAgent()}
+	if len(autor.userAgent) > maxUserAgent {
+		autor.userAgent = autor.userAgent[:maxUserAgent]
+	}
+	gc.Request = gc.Request.WithContext(context.WithValue(gc.Request.Context(), claveAutorCtx{}, autor))
+	gc.Next()
+}
+
+func autorDeContexto(ctx context.Context) *autorCambio {
+	autor, _ := ctx.Value(claveAutorCtx{}).(*autorCambio)
+	return autor
+}
+
+func (a *autorCambio) fijarUsuario(usuario Usuario) {
+	if a != nil {
+		a.usuario = &usuario
+	}
+}
+
+func (a *autorCambio) agregarInvitacion(invitacion Invitacion) {
+	if a != nil {
+		a.invitaciones = append(a.invitaciones, invitacion)
+	}
+}
+
+// para devuelve el autor de un cambio al invitado o la familia idText. El
+// lote resuelve una invitación por invitado, así que a cada cambio le toca la
+// suya; si ninguna coincide vale la última.
+func (a *autorCambio) para(idText string) AutorAuditoria {
+	if a == nil {
+		return AutorAuditoria{Tipo: autorSistema}
+	}
+
+	autor := AutorAuditoria{Tipo: autorSistema, Ip: a.ip, User_agent: a.userAgent}
+	if a.usuario != nil {
+		autor.Tipo, autor.Nombre = autorUsuario, a.usuario.Nombre
+		return autor
+	}
+	if len(a.invitaciones) == 0 {
+		return autor
+	}
+
+	invitacion := a.invitaciones[len(a.invitaciones)-1]
+	for _, i := range a.invitaciones {
+		if i.Id_text == idText {
+			invitacion = i
+			break
+		}
+	}
+	autor.Tipo, autor.Nombre, autor.Huella = invitacion.Tipo, invitacion.Id_text, huellaToken(invitacion.Token)
+	return autor
+}
+
+// huellaToken son los primeros 16 caracteres del SHA-256 del token.
+func huellaToken(token string) string {
+	return hashSecreto(token)[:16]
+}
+
+// getHistorialInvitado devuelve los cambios al invitado, del más viejo al más
+// nuevo. El historial queda aunque el invitado se haya eliminado.
+func (s *servidor) getHistorialInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+	ctx := gc.Request.Context()
+
+	historial, err := s.auditoria.HistorialInvitado(ctx, id)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	if len(historial) == 0 {
+		if _, err := s.invitados.InvitadoPorId(ctx, id); err != nil {
+			s.responderError(gc, errorInvitado(id, err))
+			return
+		}
+	}
+
+	s.json(gc, http.StatusOK, historial)
+}
+
+// getHistorialFamilia devuelve la línea de tiempo de la familia: los cambios
+// a ella y a quienes eran sus miembros al momento de cada cambio.
+func (s *servidor) getHistorialFamilia(gc *gin.Context) {
+	id := gc.Param("id")
+	ctx := gc.Request.Context()
+
+	historial, err := s.auditoria.HistorialFamilia(ctx, id)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	if len(historial) == 0 {
+		if _, err := s.familias.FamiliaPorId(ctx, id); err != nil {
+			s.responderError(gc, errorFamilia(id, err))
+			return
+		}
+	}
+
+	s.json(gc, http.StatusOK, historial)
+}
+
+// getHistorialEvento devuelve los cambios al evento, aunque ya se haya
+// eliminado. Las invitaciones y respuestas quedan en el historial de cada
+// invitado.
+func (s *servidor) getHistorialEvento(gc *gin.Context) {
+	id := gc.Param("id")
+	ctx := gc.Request.Context()
+
+	historial, err := s.auditoria.HistorialRecurso(ctx, recursoEvento, id)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	if len(historial) == 0 {
+		if _, err := s.eventos.EventoPorId(ctx, id); err != nil {
+			s.responderError(gc, errorEvento(id, err))
+			return
+		}
+	}
+
+	s.json(gc, http.StatusOK, historial)
+}
+
+// getHistorialPlatillo devuelve los cambios a la opción del menú, aunque ya
+// se haya eliminado.
+func (s *servidor) getHistorialPlatillo(gc *gin.Context) {
+	id := gc.Param("id")
+	ctx := gc.Request.Context()
+
+	historial, err := s.auditoria.HistorialRecurso(ctx, recursoPlatillo, id)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	if len(historial) == 0 {
+		if _, err := s.menus.PlatilloPorId(ctx, id); err != nil {
+			s.responderError(gc, errorMenu(id, err))
+			return
+		}
+	}
+
+	s.json(gc, http.StatusOK, historial)
+}
+
+// getHistorialAdmin devuelve las altas de usuarios y los tokens de API
+// creados y revocados.
+func (s *servidor) getHistorialAdmin(gc *gin.Context) {
+	historial, err := s.auditoria.HistorialAdmin(gc.Request.Context())
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusOK, historial)
+}
@@ -0,0 +1,195 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Roles de los usuarios de administración. La pareja administra todo, incluidos
+// los usuarios; el planeador gestiona invitados y familias; el proveedor solo
+// puede consultar la tabla de respuestas y las exportaciones.
+const (
+	rolPareja    = "pareja"
+	rolPlaneador = "planeador"
+	rolProveedor = "proveedor"
+)
+
+var rolesGestion = []string{rolPareja, rolPlaneador}
+var rolesLectura = []string{rolPareja, rolPlaneador, rolProveedor}
+
+const (
+	cookieSesion    = "sesion"
+	duracionSesion  = 7 * 24 * time.Hour
+	claveUsuarioCtx = "usuario"
+)
+
+// Usuario es una persona con acceso a las rutas de administración.
+type Usuario struct {
+	Id     int64  `json:"id"`
+	Nombre string `json:"nombre"`
+	Rol    string `json:"rol"`
+}
+
+func rolValido(rol string) bool {
+	for _, r := range rolesLectura {
+		if r == rol {
+			return true
+		}
+	}
+	return false
+}
+
+// generarSecreto devuelve un valor aleatorio de 256 bits para sesiones y
+// tokens de API.
+func generarSecreto() (string, error) {
+	bytes := make([]byte, 32)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+// hashSecreto es lo que se guarda en la base en lugar del secreto. Como los
+// secretos son aleatorios y largos basta con SHA-256, sin sal.
+func hashSecreto(secreto string) string {
+	suma := sha256.Sum256([]byte(secreto))
+	return hex.EncodeToString(suma[:])
+}
+
+// autenticar identifica a quien hace la petición por su token de API
+// ("Authorization: Bearer <token>") o por la cookie de sesión. ADMINTOKEN
+// sigue funcionando como token con rol de pareja para crear el primer usuario.
+func (s *servidor) autenticar(gc *gin.Context) (Usuario, error) {
+	ctx := gc.Request.Context()
+
+	if cabecera := gc.GetHeader("Authorization"); strings.HasPrefix(cabecera, "Bearer ") {
+		token := strings.TrimPrefix(cabecera, "Bearer ")
+
+		esperado := os.Getenv("ADMINTOKEN")
+		if esperado != "" && subtle.ConstantTimeCompare([]byte(esperado), []byte(token)) == 1 {
+			return Usuario{Nombre: "ADMINTOKEN", Rol: rolPareja}, nil
+		}
+
+		return s.usuarios.UsuarioPorTokenApi(ctx, hashSecreto(token))
+	}
+
+	sesion, err := gc.Cookie(cookieSesion)
+	if err != nil || sesion == "" {
+		return Usuario{}, errCredencialInvalida
+	}
+
+	return s.usuarios.UsuarioPorSesion(ctx, hashSecreto(sesion))
+}
+
+// requiereRol deja pasar solo a usuarios autenticados con alguno de los roles
+// indicados: 401 si no hay credenciales válidas y 403 si el rol no alcanza.
+func (s *servidor) requiereRol(roles ...string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		usuario, err := s.autenticar(gc)
+		if errors.Is(err, errCredencialInvalida) {
+			s.responderError(gc, &errorApi{Tipo: tipoNoAutorizado, Mensaje: "No autorizado"})
+			return
+		}
+		if err != nil {
+			s.responderError(gc, err)
+			return
+		}
+
+		for _, rol := range roles {
+			if usuario.Rol == rol {
+				gc.Set(claveUsuarioCtx, usuario)
+				autorDeContexto(gc.Request.Context()).fijarUsuario(usuario)
+				gc.Next()
+				return
+			}
+		}
+
+		s.responderError(gc, &errorApi{Tipo: tipoProhibido, Mensaje: "No tienes permiso para esta acción"})
+	}
+}
+
+// gestionOInvitado protege los recursos que comparten la gestión y los
+// invitados. Con credenciales se exige un rol de gestión; sin ellas la
+// petición sigue como la de un invitado, con los límites de limite.
+func (s *servidor) gestionOInvitado(limite gin.HandlerFunc) gin.HandlerFunc {
+	gestion := s.requiereRol(rolesGestion...)
+	return func(gc *gin.Context) {
+		if tieneCredenciales(gc) {
+			gestion(gc)
+			return
+		}
+		limite(gc)
+	}
+}
+
+// protegerSesion evita que otra página use la cookie de sesión para hacer
+// cambios (CSRF). Con la cookie, un POST, PUT, PATCH o DELETE debe venir de
+// un origen de CORSORIGENES o del propio backend y, si trae cuerpo, en JSON o
+// multipart para las importaciones. Los tokens de API no pasan por aquí: otra
+// página no los puede mandar.
+func (s *servidor) protegerSesion(gc *gin.Context) {
+	switch gc.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		gc.Next()
+		return
+	}
+	sesion, err := gc.Cookie(cookieSesion)
+	if strings.HasPrefix(gc.GetHeader("Authorization"), "Bearer ") || err != nil || sesion == "" {
+		gc.Next()
+		return
+	}
+
+	if !s.origenPermitido(gc) {
+		s.responderError(gc, &errorApi{Tipo: tipoProhibido, Mensaje: "Origen no permitido"})
+		return
+	}
+	if gc.Request.ContentLength != 0 && gc.ContentType() != gin.MIMEJSON && gc.ContentType() != gin.MIMEMultipartPOSTForm {
+		s.responderError(gc, entradaInvalida("El cuerpo de la petición debe ser JSON"))
+		return
+	}
+
+	gc.Next()
+}
+
+// origenPermitido indica si el Origin de la petición está en la lista de CORS
+// o es el del propio backend. Sin Origin no se acepta: los navegadores lo
+// mandan en todos los cambios.
+func (s *servidor) origenPermitido(gc *gin.Context) bool {
+	origen := gc.GetHeader("Origin")
+	if s.politicaCors.origenes[origen] {
+		return true
+	}
+	u, err := url.Parse(origen)
+	return err == nil && u.Host != "" && u.Host == gc.Request.Host
+}
+
+func tieneCredenciales(gc *gin.Context) bool {
+	if strings.HasPrefix(gc.GetHeader("Authorization"), "Bearer ") {
+		return true
+	}
+	sesion, err := gc.Cookie(cookieSesion)
+	return err == nil && sesion != ""
+}
+
+// esGestion indica si requiereRol autenticó la petición.
+func esGestion(gc *gin.Context) bool {
+	_, ok := gc.Get(claveUsuarioCtx)
+	return ok
+}
+
+// usuarioActual devuelve el usuario que dejó requiereRol en el contexto.
+func usuarioActual(gc *gin.Context) Usuario {
+	usuario, _ := gc.MustGet(claveUsuarioCtx).(Usuario)
+	return usuario
+}
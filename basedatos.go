@@ -0,0 +1,54 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/go-sql-driver/mysql"
+	_ "modernc.org/sqlite"
+)
+
+// abrirBaseDeDatos conecta con la base configurada y comprueba que responda.
+func abrirBaseDeDatos(cfg configuracion) (*sql.DB, error) {
+	switch cfg.Driver {
+	case driverMySQL:
+		return abrirMySQL(cfg.MySQL)
+	case driverSQLite:
+		return abrirSQLite(cfg.RutaSQLite)
+	default:
+		return nil, fmt.Errorf("DBDRIVER desconocido %q, usa %q o %q", cfg.Driver, driverMySQL, driverSQLite)
+	}
+}
+
+func abrirMySQL(cfg mysql.Config) (*sql.DB, error) {
+	// Get a database handle.
+	db, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(2)
+
+	return db, nil
+}
+
+// abrirSQLite abre (o crea) el archivo de la base. SQLite admite un solo
+// escritor, así que se usa una única conexión.
+func abrirSQLite(ruta string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite", "file:"+ruta+"?_pragma=busy_timeout(5000)")
+	if err != nil {
+		return nil, err
+	}
+
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
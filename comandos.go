@@ -0,0 +1,488 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const comandoServir = "serve"
+
+const usoComandos = `uso: backend <comando> [argumentos]
+
+comandos:
+  serve                          inicia el servidor HTTP (por defecto)
+  migrate up | down [pasos] | status
+                                 aplica, revierte o lista las migraciones
+  import [--dry-run] [--eliminar] <invitados.csv|invitados.xlsx>
+                                 sincroniza invitados y familias con una hoja de cálculo
+  export [--asistencia estado] <rsvp.csv|rsvp.xlsx|rsvp.json>
+                                 escribe las respuestas ("-" para CSV por la salida estándar)
+  seed --demo [--forzar]         carga invitados de ejemplo
+  token list                     lista los tokens de invitación vigentes
+  token regenerate [--expira duración] invitado|familia <id_text>
+                                 revoca el token de invitación y emite uno nuevo
+  token revoke invitado|familia <id_text>
+                                 revoca el token de invitación sin emitir otro
+  link sign [--alcances a,b] [--expira duración] invitado|familia <id_text>|--todos
+                                 firma enlaces de invitación con la clave de FIRMAENLACES
+  user add [--rol pareja|planeador|proveedor] <nombre>
+                                 crea un usuario de administración (pide la clave)
+  user token <nombre> <descripción>
+                                 crea un token de API para el usuario
+`
+
+// ejecutarComando abre la base con la configuración compartida y atiende el
+// subcomando pedido.
+func ejecutarComando(cfg configuracion, comando string, args []string) error {
+	switch comando {
+	case "help", "-h", "--help":
+		fmt.Print(usoComandos)
+		return nil
+	case comandoServir, "migrate", "import", "export", "seed", "token", "user", "link":
+	default:
+		fmt.Fprint(os.Stderr, usoComandos)
+		return fmt.Errorf("comando desconocido %q", comando)
+	}
+
+	db, err := abrirBaseDeDatos(cfg)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if comando == "migrate" {
+		return comandoMigrar(ctx, db, cfg.Driver, args)
+	}
+
+	if cfg.MigrarAlIniciar {
+		if _, err := migrarArriba(ctx, db, cfg.Driver); err != nil {
+			return err
+		}
+	}
+
+	store := nuevoSqlStore(db)
+
+	firmas, err := nuevoFirmador(cfg.ClavesFirma)
+	if err != nil {
+		return err
+	}
+
+	switch comando {
+	case "import":
+		return comandoImportar(ctx, store, args)
+	case "export":
+		return comandoExportar(ctx, store, args)
+	case "seed":
+		return comandoSembrar(ctx, store, args)
+	case "token":
+		return comandoToken(ctx, store, args)
+	case "user":
+		return comandoUsuario(ctx, store, args)
+	case "link":
+		return comandoEnlace(ctx, store, firmas, args)
+	}
+
+	plantillas, err := cargarPlantillas(cfg.DirPlantillas, cfg.RecargarPlantillas, cfg.URLBase)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("connected!")
+
+	srv := nuevoServidor(store, store, store, store, store, store, store, store, store)
+	srv.firmas = firmas
+	srv.plantillas = plantillas
+	srv.limitador = nuevoLimitadorMemoria()
+	srv.limites = cfg.Limites
+	srv.plazo = cfg.PlazoRsvp
+	srv.cabeceraIP = cfg.CabeceraIP
+	srv.aceptarIdText = cfg.AceptarIdText
+	srv.politicaCors = cfg.Cors
+	srv.urlBase = cfg.URLBase
+	return srv.rutas().Run(cfg.Puerto)
+}
+
+// comandoMigrar atiende `migrate up`, `migrate down [pasos]` y `migrate status`.
+func comandoMigrar(ctx context.Context, db *sql.DB, driver string, args []string) error {
+	if len(args) == 0 {
+		return errors.New("uso: migrate up | down [pasos] | status")
+	}
+
+	switch args[0] {
+	case "up":
+		aplicadas, err := migrarArriba(ctx, db, driver)
+		for _, m := range aplicadas {
+			fmt.Printf("aplicada %04d_%s\n", m.Version, m.Nombre)
+		}
+		if err == nil && len(aplicadas) == 0 {
+			fmt.Println("no hay migraciones pendientes")
+		}
+		return err
+	case "down":
+		pasos := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n < 1 {
+				return fmt.Errorf("número de pasos inválido %q", args[1])
+			}
+			pasos = n
+		}
+		revertidas, err := migrarAbajo(ctx, db, driver, pasos)
+		for _, m := range revertidas {
+			fmt.Printf("revertida %04d_%s\n", m.Version, m.Nombre)
+		}
+		return err
+	case "status":
+		estados, err := estadoDeMigraciones(ctx, db, driver)
+		if err != nil {
+			return err
+		}
+		for _, e := range estados {
+			aplicada := "pendiente"
+			if e.AplicadaEn.Valid {
+				aplicada = e.AplicadaEn.Time.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%04d_%s\t%s\n", e.Version, e.Nombre, aplicada)
+		}
+		return nil
+	default:
+		return fmt.Errorf("subcomando de migrate desconocido %q", args[0])
+	}
+}
+
+func comandoImportar(ctx context.Context, store *sqlStore, args []string) error {
+	banderas := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := banderas.Bool("dry-run", false, "muestra los cambios sin aplicarlos")
+	eliminar := banderas.Bool("eliminar", false, "elimina los invitados que no están en el archivo")
+	if err := banderas.Parse(args); err != nil {
+		return err
+	}
+
+	if banderas.NArg() != 1 {
+		return errors.New("uso: import [--dry-run] [--eliminar] <invitados.csv|invitados.xlsx>")
+	}
+
+	archivo, err := os.Open(banderas.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer archivo.Close()
+
+	filas, conFamilias, err := leerArchivoInvitados(archivo.Name(), archivo)
+	if err != nil {
+		return err
+	}
+
+	plan, err := prepararImportacion(ctx, store, store, filas, conFamilias)
+	if err != nil {
+		return err
+	}
+
+	imprimirPlan(os.Stdout, plan, *eliminar)
+
+	if *dryRun {
+		fmt.Println("modo de prueba, no se aplicó ningún cambio")
+		return nil
+	}
+
+	return store.AplicarImportacion(ctx, plan, *eliminar)
+}
+
+func comandoExportar(ctx context.Context, store *sqlStore, args []string) error {
+	banderas := flag.NewFlagSet("export", flag.ContinueOnError)
+	asistencia := banderas.String("asistencia", "", "solo invitados con ese estado: sin-respuesta, si-asiste o no-asiste")
+	if err := banderas.Parse(args); err != nil {
+		return err
+	}
+
+	if banderas.NArg() != 1 {
+		return errors.New("uso: export [--asistencia estado] <rsvp.csv|rsvp.xlsx|rsvp.json>")
+	}
+
+	invitados, err := store.InvitadosConFamilia(ctx)
+	if err != nil {
+		return err
+	}
+
+	destino := banderas.Arg(0)
+	var salida io.Writer = os.Stdout
+	if destino != "-" {
+		archivo, err := os.Create(destino)
+		if err != nil {
+			return err
+		}
+		defer archivo.Close()
+		salida = archivo
+	}
+
+	return escribirRsvp(salida, formatoPorArchivo(destino), filasRsvp(invitados, *asistencia))
+}
+
+// comandoSembrar carga unas familias de ejemplo para probar el frontend. Se
+// niega a hacerlo si ya hay invitados, salvo con --forzar.
+func comandoSembrar(ctx context.Context, store *sqlStore, args []string) error {
+	banderas := flag.NewFlagSet("seed", flag.ContinueOnError)
+	demo := banderas.Bool("demo", false, "carga invitados y familias de ejemplo")
+	forzar := banderas.Bool("forzar", false, "carga los datos aunque ya existan invitados")
+	if err := banderas.Parse(args); err != nil {
+		return err
+	}
+
+	if !*demo {
+		return errors.New("uso: seed --demo [--forzar]")
+	}
+
+	existentes, err := store.ListarInvitados(ctx)
+	if err != nil {
+		return err
+	}
+	if len(existentes) > 0 && !*forzar {
+		return fmt.Errorf("ya hay %v invitados, usa --forzar para agregar los de ejemplo de todas formas", len(existentes))
+	}
+
+	filas := []filaImportacion{
+		{Nombre: "Carlos Gómez", Nombre_invitacion: "Carlos", Familia: "Familia Gómez", Miembro_principal: true},
+		{Nombre: "Lucía Gómez", Nombre_invitacion: "Lucía", Familia: "Familia Gómez"},
+		{Nombre: "Mateo Gómez", Nombre_invitacion: "Mateo", Familia: "Familia Gómez"},
+		{Nombre: "Sofía Restrepo", Nombre_invitacion: "Sofi", Familia: "Familia Restrepo", Miembro_principal: true},
+		{Nombre: "Andrés Restrepo", Nombre_invitacion: "Andrés", Familia: "Familia Restrepo"},
+		{Nombre: "Valentina Ruiz", Nombre_invitacion: "Vale"},
+		{Nombre: "Juan Pablo Ortiz", Nombre_invitacion: "Juanpa"},
+	}
+
+	plan, err := prepararImportacion(ctx, store, store, filas, true)
+	if err != nil {
+		return err
+	}
+	if err := store.AplicarImportacion(ctx, plan, false); err != nil {
+		return err
+	}
+
+	// Algunas respuestas para que la tabla de RSVP muestre los tres estados.
+	invitados, err := store.ListarInvitados(ctx)
+	if err != nil {
+		return err
+	}
+	respuestas := map[string]bool{"Carlos Gómez": true, "Lucía Gómez": true, "Andrés Restrepo": false, "Valentina Ruiz": true}
+	for _, invitado := range invitados {
+		if asiste, ok := respuestas[invitado.Nombre]; ok {
+			if _, err := store.RegistrarAsistencia(ctx, invitado.Id_text, asiste); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Printf("invitados de ejemplo: %v, familias: %v\n", len(plan.Nuevos), len(plan.Familias_nuevas))
+	return nil
+}
+
+// comandoToken administra los tokens de los enlaces de invitación: `token
+// list`, `token regenerate` (revoca el vigente y emite otro) y `token revoke`.
+func comandoToken(ctx context.Context, store *sqlStore, args []string) error {
+	const uso = "uso: token list | token regenerate [--expira duración] invitado|familia <id_text> | token revoke invitado|familia <id_text>"
+	if len(args) == 0 {
+		return errors.New(uso)
+	}
+
+	if args[0] == "list" {
+		invitaciones, err := store.ListarInvitaciones(ctx)
+		if err != nil {
+			return err
+		}
+		for _, inv := range invitaciones {
+			expira := "-"
+			if inv.Expira_en != nil {
+				expira = inv.Expira_en.Format(time.RFC3339)
+			}
+			fmt.Printf("%s\t%s\t%s\t%s\t%s\n", inv.Tipo, inv.Id_text, inv.Token, expira, inv.Nombre)
+		}
+		return nil
+	}
+
+	banderas := flag.NewFlagSet("token "+args[0], flag.ContinueOnError)
+	duracion := banderas.Duration("expira", 0, "tiempo de validez del nuevo token, por ejemplo 720h")
+	if err := banderas.Parse(args[1:]); err != nil {
+		return err
+	}
+	if banderas.NArg() != 2 {
+		return errors.New(uso)
+	}
+
+	tipo, idText := banderas.Arg(0), banderas.Arg(1)
+	if tipo != invitacionInvitado && tipo != invitacionFamilia {
+		return fmt.Errorf("tipo desconocido %q, usa invitado o familia", tipo)
+	}
+
+	switch args[0] {
+	case "regenerate":
+		var expira time.Time
+		if *duracion > 0 {
+			expira = time.Now().Add(*duracion)
+		}
+		invitacion, err := store.RotarInvitacion(ctx, tipo, idText, expira)
+		if err != nil {
+			return err
+		}
+		fmt.Println(invitacion.Token)
+		return nil
+	case "revoke":
+		return store.RevocarInvitacion(ctx, tipo, idText)
+	default:
+		return fmt.Errorf("subcomando de token desconocido %q", args[0])
+	}
+}
+
+// comandoUsuario atiende `user add` y `user token`. La clave se lee de la
+// entrada estándar para que no quede en el historial de la terminal.
+func comandoUsuario(ctx context.Context, store *sqlStore, args []string) error {
+	if len(args) == 0 {
+		return errors.New("uso: user add [--rol rol] <nombre> | user token <nombre> <descripción>")
+	}
+
+	switch args[0] {
+	case "add":
+		banderas := flag.NewFlagSet("user add", flag.ContinueOnError)
+		rol := banderas.String("rol", rolPlaneador, "pareja, planeador o proveedor")
+		if err := banderas.Parse(args[1:]); err != nil {
+			return err
+		}
+		if banderas.NArg() != 1 {
+			return errors.New("uso: user add [--rol pareja|planeador|proveedor] <nombre>")
+		}
+
+		fmt.Fprint(os.Stderr, "clave: ")
+		clave, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return err
+		}
+
+		usuario, err := crearUsuario(ctx, store, UsuarioCommand{Nombre: banderas.Arg(0), Clave: strings.TrimRight(clave, "\r\n"), Rol: *rol})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("usuario %s creado con rol %s\n", usuario.Nombre, usuario.Rol)
+		return nil
+	case "token":
+		if len(args) != 3 {
+			return errors.New("uso: user token <nombre> <descripción>")
+		}
+
+		usuario, _, err := store.UsuarioPorNombre(ctx, args[1])
+		if err != nil {
+			return err
+		}
+
+		token, err := crearTokenApi(ctx, store, usuario.Id, args[2])
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(token.Token)
+		return nil
+	default:
+		return fmt.Errorf("subcomando de user desconocido %q", args[0])
+	}
+}
+
+// comandoEnlace firma enlaces para imprimir en las invitaciones. Con --todos
+// firma uno por cada invitado o familia y los lista separados por tabuladores.
+// Las banderas pueden ir antes o después del tipo, como en link sign familia
+// --todos.
+func comandoEnlace(ctx context.Context, store *sqlStore, firmas *firmador, args []string) error {
+	const uso = "uso: link sign [--alcances rsvp,cancion,mensaje] [--expira duración] invitado|familia <id_text>|--todos"
+	if len(args) == 0 || args[0] != "sign" {
+		return errors.New(uso)
+	}
+
+	banderas := flag.NewFlagSet("link sign", flag.ContinueOnError)
+	alcances := banderas.String("alcances", strings.Join(alcancesEnlace, ","), "acciones que permite el enlace")
+	duracion := banderas.Duration("expira", 0, "tiempo de validez del enlace, por ejemplo 2160h")
+	todos := banderas.Bool("todos", false, "firma un enlace por cada invitado o familia")
+	// flag deja de leer banderas en el primer argumento, así que se vuelve a
+	// llamar con lo que sigue a cada uno.
+	var posicionales []string
+	for resto := args[1:]; ; resto = banderas.Args()[1:] {
+		if err := banderas.Parse(resto); err != nil {
+			return err
+		}
+		if banderas.NArg() == 0 {
+			break
+		}
+		posicionales = append(posicionales, banderas.Arg(0))
+	}
+
+	var expira time.Time
+	if *duracion > 0 {
+		expira = time.Now().Add(*duracion)
+	}
+
+	if len(posicionales) < 1 {
+		return errors.New(uso)
+	}
+	tipo := posicionales[0]
+
+	type destino struct{ idText, nombre string }
+	var destinos []destino
+	switch {
+	case *todos && len(posicionales) == 1 && tipo == invitacionFamilia:
+		familias, err := store.ListarFamilias(ctx)
+		if err != nil {
+			return err
+		}
+		for _, f := range familias {
+			destinos = append(destinos, destino{f.Id_text, f.Nombre})
+		}
+	case *todos && len(posicionales) == 1 && tipo == invitacionInvitado:
+		invitados, err := store.ListarInvitados(ctx)
+		if err != nil {
+			return err
+		}
+		for _, i := range invitados {
+			if i.Anfitrion == nil {
+				destinos = append(destinos, destino{i.Id_text, i.Nombre})
+			}
+		}
+	case !*todos && len(posicionales) == 2 && tipo == invitacionFamilia:
+		familia, err := store.FamiliaPorId(ctx, posicionales[1])
+		if err != nil {
+			return err
+		}
+		destinos = append(destinos, destino{familia.Id_text, familia.Nombre})
+	case !*todos && len(posicionales) == 2 && tipo == invitacionInvitado:
+		invitado, err := store.InvitadoPorId(ctx, posicionales[1])
+		if err != nil {
+			return err
+		}
+		if invitado.Anfitrion != nil {
+			return errInvitadoEsAcompanante
+		}
+		destinos = append(destinos, destino{invitado.Id_text, invitado.Nombre})
+	default:
+		return errors.New(uso)
+	}
+
+	for _, d := range destinos {
+		enlace, err := firmas.firmar(tipo, d.idText, strings.Split(*alcances, ","), expira)
+		if err != nil {
+			return err
+		}
+		if *todos {
+			fmt.Printf("%s\t%s\t%s\n", d.idText, d.nombre, enlace)
+		} else {
+			fmt.Println(enlace)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+	driverMySQL  = "mysql"
+	driverSQLite = "sqlite"
+)
+
+// configuracion reúne las variables de entorno que usa el backend.
+type configuracion struct {
+	// Driver es "mysql" (por defecto) o "sqlite".
+	Driver     string
+	MySQL      mysql.Config
+	RutaSQLite string
+	Puerto     string
+	// MigrarAlIniciar aplica las migraciones pendientes antes de servir.
+	MigrarAlIniciar bool
+	// AceptarIdText deja que los enlaces viejos, que usan el id_text, sigan
+	// funcionando mientras se envían los nuevos con token.
+	AceptarIdText bool
+	// ClavesFirma son las claves de los enlaces firmados, "id:secreto,..."; la
+	// primera firma y el resto solo verifican.
+	ClavesFirma string
+	// CabeceraIP es la cabecera con la IP real del cliente que pone el proxy,
+	// por ejemplo Fly-Client-IP. Vacía usa la conexión y X-Forwarded-For.
+	CabeceraIP string
+	Limites    limitesPeticiones
+	// PlazoRsvp es la fecha límite global para responder, ver cargarPlazoRsvp.
+	PlazoRsvp plazoRsvp
+	// DirPlantillas tiene archivos .html que reemplazan a las plantillas
+	// embebidas; con RecargarPlantillas se releen en cada petición.
+	DirPlantillas      string
+	RecargarPlantillas bool
+	// URLBase es la URL pública del backend que usan los fragmentos en hx-post,
+	// sin "/" final. Vacía genera rutas relativas, para cuando el frontend se
+	// sirve desde el mismo dominio.
+	URLBase string
+	// Cors son los orígenes del frontend, ver parsearCors.
+	Cors politicaCors
+}
+
+func cargarConfiguracion() (configuracion, error) {
+	cfg := configuracion{
+		Driver: os.Getenv("DBDRIVER"),
+		// Capture connection properties.
+		MySQL: mysql.Config{
+			User:                 os.Getenv("DBUSER"),
+			Passwd:               os.Getenv("DBPASS"),
+			Net:                  "tcp",
+			Addr:                 os.Getenv("DBADRESS"),
+			DBName:               os.Getenv("DBNAME"),
+			AllowNativePasswords: true,
+			ParseTime:            true,
+		},
+		RutaSQLite:  os.Getenv("DBPATH"),
+		Puerto:      os.Getenv("LOCALPORT"),
+		ClavesFirma: os.Getenv("FIRMAENLACES"),
+		CabeceraIP:  os.Getenv("IPCABECERA"),
+
+		DirPlantillas:      os.Getenv("PLANTILLASDIR"),
+		RecargarPlantillas: os.Getenv("PLANTILLASRECARGAR") == "true",
+	}
+
+	if cfg.Driver == "" {
+		cfg.Driver = driverMySQL
+	}
+	if cfg.RutaSQLite == "" {
+		cfg.RutaSQLite = "wedding.db"
+	}
+
+	// Con SQLite la base suele ser un archivo nuevo, así que por defecto se
+	// crea el esquema al arrancar.
+	cfg.MigrarAlIniciar = os.Getenv("AUTOMIGRATE") == "true" ||
+		(cfg.Driver == driverSQLite && os.Getenv("AUTOMIGRATE") != "false")
+
+	cfg.AceptarIdText = os.Getenv("IDTEXTLEGADO") == "true"
+
+	urlBase, err := parsearURLBase(os.Getenv("URLBASE"))
+	if err != nil {
+		return cfg, err
+	}
+	cfg.URLBase = urlBase
+
+	// Por defecto cualquier origen puede leer las rutas de invitados, como
+	// antes; el panel necesita que su origen esté en la lista.
+	origenes, ok := os.LookupEnv("CORSORIGENES")
+	if !ok {
+		origenes = "*"
+	}
+	cors, err := parsearCors(origenes)
+	if err != nil {
+		return cfg, fmt.Errorf("CORSORIGENES: %s", err)
+	}
+	cfg.Cors = cors
+
+	limites, err := cargarLimites()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Limites = limites
+
+	plazo, err := cargarPlazoRsvp()
+	if err != nil {
+		return cfg, err
+	}
+	cfg.PlazoRsvp = plazo
+
+	return cfg, nil
+}
+
+// parsearURLBase acepta una URL absoluta http o https, con o sin ruta, y le
+// quita la "/" final.
+func parsearURLBase(valor string) (string, error) {
+	if valor == "" {
+		return "", nil
+	}
+
+	u, err := url.Parse(valor)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("URLBASE inválida %q, usa algo como https://wedding-back.fly.dev", valor)
+	}
+
+	return strings.TrimSuffix(valor, "/"), nil
+}
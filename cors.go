@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Métodos y cabeceras que puede usar el frontend. Las hx-* son las que manda
+// htmx en cada petición.
+const (
+	metodosCors   = "GET, POST, PUT, PATCH, DELETE, OPTIONS"
+	cabecerasCors = "Authorization, Content-Type, hx-boosted, hx-current-url, hx-history-restore-request, hx-prompt, hx-request, hx-target, hx-trigger, hx-trigger-name"
+	// exponerCors son las cabeceras de respuesta que el navegador deja leer:
+	// la espera del límite y el nombre del archivo exportado.
+	exponerCors = "Content-Disposition, Retry-After"
+	maxAgeCors  = "600"
+)
+
+// politicaCors decide a qué orígenes se les responde con cabeceras CORS. Los
+// orígenes de la lista reciben credenciales, así el panel puede usar la
+// cookie de sesión si está en el mismo sitio que el backend; con "*" el resto también puede leer las respuestas, pero
+// sin credenciales.
+type politicaCors struct {
+	origenes map[string]bool
+	todos    bool
+}
+
+// parsearCors lee orígenes como "https://a.com,https://b.com"; "*" acepta
+// cualquiera sin credenciales y se puede combinar con la lista.
+func parsearCors(valor string) (politicaCors, error) {
+	p := politicaCors{origenes: map[string]bool{}}
+	for _, parte := range strings.Split(valor, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		if parte == "*" {
+			p.todos = true
+			continue
+		}
+
+		u, err := url.Parse(parte)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.TrimSuffix(u.Path, "/") != "" || u.RawQuery != "" || u.Fragment != "" {
+			return p, fmt.Errorf("origen inválido %q, usa algo como https://wedding.example.com", parte)
+		}
+		// El navegador manda el origen sin "/" final y con el esquema y el
+		// host en minúsculas.
+		p.origenes[strings.ToLower(u.Scheme+"://"+u.Host)] = true
+	}
+	return p, nil
+}
+
+// cors pone las cabeceras antes que el resto de middlewares, así también las
+// llevan los 401, 403 y 429. Responde las peticiones preflight sin llegar a
+// las rutas, que no necesitan registrar OPTIONS.
+func (s *servidor) cors(gc *gin.Context) {
+	origen := gc.GetHeader("Origin")
+	if origen == "" {
+		gc.Next()
+		return
+	}
+
+	gc.Header("Vary", "Origin")
+	switch {
+	case s.politicaCors.origenes[origen]:
+		gc.Header("Access-Control-Allow-Origin", origen)
+		gc.Header("Access-Control-Allow-Credentials", "true")
+	case s.politicaCors.todos:
+		gc.Header("Access-Control-Allow-Origin", "*")
+	default:
+		// Sin cabeceras el navegador bloquea la respuesta; la petición se
+		// atiende igual porque puede venir de un cliente que no es navegador.
+		if gc.Request.Method == http.MethodOptions {
+			gc.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		gc.Next()
+		return
+	}
+	gc.Header("Access-Control-Expose-Headers", exponerCors)
+
+	if gc.Request.Method == http.MethodOptions && gc.GetHeader("Access-Control-Request-Method") != "" {
+		gc.Header("Access-Control-Allow-Methods", metodosCors)
+		gc.Header("Access-Control-Allow-Headers", cabecerasCors)
+		gc.Header("Access-Control-Max-Age", maxAgeCors)
+		gc.AbortWithStatus(http.StatusNoContent)
+		return
+	}
+
+	gc.Next()
+}
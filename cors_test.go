@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestParsearCors(t *testing.T) {
+	casos := []struct {
+		nombre   string
+		valor    string
+		origenes []string
+		todos    bool
+		falla    bool
+	}{
+		{"vacío", "", nil, false, false},
+		{"lista", "https://a.com, https://b.com:8443", []string{"https://a.com", "https://b.com:8443"}, false, false},
+		{"normaliza", "HTTPS://A.com/", []string{"https://a.com"}, false, false},
+		{"comodín con lista", "*,http://localhost:3000", []string{"http://localhost:3000"}, true, false},
+		{"sin esquema", "a.com", nil, false, true},
+		{"esquema no web", "ftp://a.com", nil, false, true},
+		{"con ruta", "https://a.com/panel", nil, false, true},
+		{"con consulta", "https://a.com?x=1", nil, false, true},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			p, err := parsearCors(c.valor)
+			if (err != nil) != c.falla {
+				t.Fatalf("parsearCors(%q) error = %v, se esperaba falla %v", c.valor, err, c.falla)
+			}
+			if c.falla {
+				return
+			}
+			if p.todos != c.todos || len(p.origenes) != len(c.origenes) {
+				t.Fatalf("parsearCors(%q) = %+v, se esperaba %v y todos %v", c.valor, p, c.origenes, c.todos)
+			}
+			for _, o := range c.origenes {
+				if !p.origenes[o] {
+					t.Errorf("parsearCors(%q) no incluye %q", c.valor, o)
+				}
+			}
+		})
+	}
+}
+
+func TestCors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	lista, err := parsearCors("https://panel.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	conTodos, err := parsearCors("*,https://panel.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre       string
+		politica     politicaCors
+		metodo       string
+		origen       string
+		preflight    bool
+		estado       int
+		permitido    string
+		credenciales string
+	}{
+		{"sin origen", lista, http.MethodGet, "", false, http.StatusOK, "", ""},
+		{"origen de la lista", lista, http.MethodGet, "https://panel.example.com", false, http.StatusOK, "https://panel.example.com", "true"},
+		{"origen ajeno se atiende sin cabeceras", lista, http.MethodGet, "https://otro.example.com", false, http.StatusOK, "", ""},
+		{"subdominio no está en la lista", lista, http.MethodGet, "https://x.panel.example.com", false, http.StatusOK, "", ""},
+		{"comodín sin credenciales", conTodos, http.MethodGet, "https://otro.example.com", false, http.StatusOK, "*", ""},
+		{"comodín respeta la lista", conTodos, http.MethodGet, "https://panel.example.com", false, http.StatusOK, "https://panel.example.com", "true"},
+		{"preflight de la lista", lista, http.MethodOptions, "https://panel.example.com", true, http.StatusNoContent, "https://panel.example.com", "true"},
+		{"preflight ajeno", lista, http.MethodOptions, "https://otro.example.com", true, http.StatusNoContent, "", ""},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			s := &servidor{politicaCors: c.politica}
+			router := gin.New()
+			router.Use(s.cors)
+			router.GET("/", func(gc *gin.Context) { gc.Status(http.StatusOK) })
+
+			peticion := httptest.NewRequest(c.metodo, "/", nil)
+			if c.origen != "" {
+				peticion.Header.Set("Origin", c.origen)
+			}
+			if c.preflight {
+				peticion.Header.Set("Access-Control-Request-Method", http.MethodPost)
+			}
+			respuesta := httptest.NewRecorder()
+			router.ServeHTTP(respuesta, peticion)
+
+			if respuesta.Code != c.estado {
+				t.Errorf("estado = %v, se esperaba %v", respuesta.Code, c.estado)
+			}
+			if got := respuesta.Header().Get("Access-Control-Allow-Origin"); got != c.permitido {
+				t.Errorf("Access-Control-Allow-Origin = %q, se esperaba %q", got, c.permitido)
+			}
+			if got := respuesta.Header().Get("Access-Control-Allow-Credentials"); got != c.credenciales {
+				t.Errorf("Access-Control-Allow-Credentials = %q, se esperaba %q", got, c.credenciales)
+			}
+			if c.preflight && c.permitido != "" && respuesta.Header().Get("Access-Control-Allow-Methods") != metodosCors {
+				t.Errorf("el preflight no trae Access-Control-Allow-Methods")
+			}
+			if c.origen != "" && respuesta.Header().Get("Vary") != "Origin" {
+				t.Errorf("falta Vary: Origin")
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tipoError clasifica los errores que ve el cliente. Cada tipo tiene su código
+// HTTP y un nombre estable en el campo "error" de la respuesta, para que el
+// frontend no dependa del texto del mensaje.
+type tipoError string
+
+const (
+	tipoNoEncontrado    tipoError = "no_encontrado"
+	tipoEntradaInvalida tipoError = "entrada_invalida"
+	tipoConflicto       tipoError = "conflicto"
+	tipoNoProcesable    tipoError = "no_procesable"
+	tipoInterno         tipoError = "interno"
+	tipoNoAutorizado    tipoError = "no_autorizado"
+	tipoProhibido       tipoError = "prohibido"
+	tipoExpirado        tipoError = "expirado"
+	tipoLimiteExcedido  tipoError = "limite_excedido"
+	tipoPlazoVencido    tipoError = "plazo_vencido"
+)
+
+var statusPorTipo = map[tipoError]int{
+	tipoNoEncontrado:    http.StatusNotFound,
+	tipoEntradaInvalida: http.StatusBadRequest,
+	tipoConflicto:       http.StatusConflict,
+	tipoNoProcesable:    http.StatusUnprocessableEntity,
+	tipoInterno:         http.StatusInternalServerError,
+	tipoNoAutorizado:    http.StatusUnauthorized,
+	tipoProhibido:       http.StatusForbidden,
+	tipoExpirado:        http.StatusGone,
+	tipoLimiteExcedido:  http.StatusTooManyRequests,
+	tipoPlazoVencido:    http.StatusForbidden,
+}
+
+const mensajeErrorInterno = "Ha sucedido un error por favor intentelo de nuevo"
+
+// errorApi es un error con un mensaje que se le puede mostrar al usuario. La
+// causa solo va al log, así el texto de la base nunca llega a la respuesta.
+type errorApi struct {
+	Tipo    tipoError
+	Mensaje string
+	// Detalles se envía tal cual en la respuesta, por ejemplo los resultados
+	// de un lote que no se aplicó.
+	Detalles any
+	causa    error
+}
+
+func (e *errorApi) Error() string {
+	if e.causa != nil {
+		return e.Mensaje + ": " + e.causa.Error()
+	}
+	return e.Mensaje
+}
+
+func (e *errorApi) Unwrap() error {
+	return e.causa
+}
+
+func (e *errorApi) status() int {
+	if status, ok := statusPorTipo[e.Tipo]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func noEncontrado(mensaje string) *errorApi {
+	return &errorApi{Tipo: tipoNoEncontrado, Mensaje: mensaje}
+}
+
+func entradaInvalida(mensaje string) *errorApi {
+	return &errorApi{Tipo: tipoEntradaInvalida, Mensaje: mensaje}
+}
+
+func conflicto(mensaje string) *errorApi {
+	return &errorApi{Tipo: tipoConflicto, Mensaje: mensaje}
+}
+
+func interno(causa error) *errorApi {
+	return &errorApi{Tipo: tipoInterno, Mensaje: mensajeErrorInterno, causa: causa}
+}
+
+// peticionIncorrecta envuelve los errores al leer el cuerpo. Son de gin o de
+// encoding/json y solo describen el JSON recibido.
+func peticionIncorrecta(err error) *errorApi {
+	return &errorApi{Tipo: tipoEntradaInvalida, Mensaje: "La petición es incorrecta: " + err.Error()}
+}
+
+// RespuestaError es el cuerpo JSON de todas las respuestas de error. message
+// se mantiene para los clientes que ya lo leían.
+type RespuestaError struct {
+	Error    tipoError `json:"error"`
+	Message  string    `json:"message"`
+	Detalles any       `json:"detalles,omitempty"`
+}
+
+// comoErrorApi devuelve el errorApi dentro de err o, si no hay, uno interno.
+// Los internos se registran con la causa, que no llega al cliente.
+func comoErrorApi(gc *gin.Context, err error) *errorApi {
+	var e *errorApi
+	if !errors.As(err, &e) {
+		e = interno(err)
+	}
+	if e.Tipo == tipoInterno {
+		log.Printf("%s %s: %s", gc.Request.Method, gc.FullPath(), err)
+	}
+	return e
+}
+
+// responderError corta la petición con el error. Si el cliente pide HTML, como
+// htmx, le llega el fragmento mensaje-error, que puede mostrar en lugar del
+// contenido; al resto, JSON.
+func (s *servidor) responderError(gc *gin.Context, err error) {
+	e := comoErrorApi(gc, err)
+
+	gc.Abort()
+	respuesta := RespuestaError{Error: e.Tipo, Message: e.Mensaje, Detalles: e.Detalles}
+	if quiereHTML(gc) {
+		s.html(gc, e.status(), "mensaje-error", respuesta)
+		return
+	}
+	gc.IndentedJSON(e.status(), respuesta)
+}
+
+// responderFormulario devuelve otra vez el campo del formulario, que es lo que
+// htmx reemplaza, con el código que corresponde al error. datos son los de la
+// plantilla: el aviso que va de placeholder en canciones y mensajes. En JSON
+// es un error como cualquier otro.
+func (s *servidor) responderFormulario(gc *gin.Context, err error, plantilla string, datos any) {
+	if !quiereHTML(gc) {
+		s.responderError(gc, err)
+		return
+	}
+	e := comoErrorApi(gc, err)
+
+	gc.Abort()
+	s.html(gc, e.status(), plantilla, datos)
+}
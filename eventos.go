@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EventoResp es uno de los eventos de la boda: civil, iglesia, recepción...
+// Orden decide en qué orden se muestran a los invitados. Limite_rsvp, si
+// viene, reemplaza a la fecha límite global para este evento. Sin_menu marca
+// los eventos en los que no se sirve la comida.
+type EventoResp struct {
+	Id          int64      `json:"id"`
+	Id_text     string     `json:"id_text"`
+	Nombre      string     `json:"nombre"`
+	Lugar       string     `json:"lugar"`
+	Fecha       *time.Time `json:"fecha"`
+	Orden       int        `json:"orden"`
+	Limite_rsvp *time.Time `json:"limite_rsvp"`
+	Sin_menu    bool       `json:"sin_menu"`
+}
+
+type EventoCommand struct {
+	Nombre      string     `json:"nombre"`
+	Lugar       string     `json:"lugar"`
+	Fecha       *time.Time `json:"fecha"`
+	Orden       int        `json:"orden"`
+	Limite_rsvp *time.Time `json:"limite_rsvp"`
+	Sin_menu    bool       `json:"sin_menu"`
+}
+
+// AsistenciaEvento es la respuesta de un invitado a uno de sus eventos; Asiste
+// es null mientras no responde.
+type AsistenciaEvento struct {
+	Evento EventoResp `json:"evento"`
+	Asiste *bool      `json:"asiste"`
+}
+
+// totalesEvento son los totales de la tabla de respuestas para un evento.
+// Acompanantes son los que ya van incluidos en Total y Aceptados.
+type totalesEvento struct {
+	Id_text       string `json:"id_text"`
+	Nombre        string `json:"nombre"`
+	Total         int    `json:"total"`
+	Sin_respuesta int    `json:"sin_respuesta"`
+	Rechazados    int    `json:"rechazados"`
+	Aceptados     int    `json:"aceptados"`
+	Acompanantes  int    `json:"acompanantes"`
+}
+
+// botonesEventoVista son los datos de la plantilla botones-evento. Menu solo
+// viene en la respuesta a un evento, como en botonesRespuestaVista.
+type botonesEventoVista struct {
+	Token  string
+	Evento EventoResp
+	Asiste sql.NullBool
+	Plazo  estadoPlazo
+	Menu   *menuVista
+}
+
+// Clave distingue los botones de cada evento de un mismo invitado en los ids
+// del HTML.
+func (v botonesEventoVista) Clave() string {
+	return v.Token + "-" + v.Evento.Id_text
+}
+
+func (v botonesEventoVista) Cerrados() botonesCerradosVista {
+	return botonesCerradosVista{Clave: v.Clave(), Asiste: v.Asiste, Plazo: v.Plazo}
+}
+
+func botonesEvento(token string, asistencia AsistenciaEvento, plazo estadoPlazo) botonesEventoVista {
+	vista := botonesEventoVista{Token: token, Evento: asistencia.Evento, Plazo: plazo}
+	if asistencia.Asiste != nil {
+		vista.Asiste = sql.NullBool{Bool: *asistencia.Asiste, Valid: true}
+	}
+	return vista
+}
+
+func (cmd *EventoCommand) normalizar() {
+	cmd.Nombre = strings.TrimSpace(cmd.Nombre)
+	cmd.Lugar = strings.TrimSpace(cmd.Lugar)
+}
+
+func (cmd EventoCommand) validar() error {
+	if cmd.Nombre == "" {
+		return errors.New("el nombre es obligatorio")
+	}
+	if utf8.RuneCountInString(cmd.Nombre) > largoMaximoNombre {
+		return fmt.Errorf("el nombre no puede superar %v caracteres", largoMaximoNombre)
+	}
+	if utf8.RuneCountInString(cmd.Lugar) > largoMaximoNombre {
+		return fmt.Errorf("el lugar no puede superar %v caracteres", largoMaximoNombre)
+	}
+	return nil
+}
+
+func (s *servidor) getEventos(gc *gin.Context) {
+	eventos, err := s.eventos.ListarEventos(gc.Request.Context())
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusOK, eventos)
+}
+
+func (s *servidor) getEvento(gc *gin.Context) {
+	id := gc.Param("id")
+
+	evento, err := s.eventos.EventoPorId(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, evento)
+}
+
+func (s *servidor) crearEvento(gc *gin.Context) {
+	var cmd EventoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	evento, err := s.eventos.CrearEvento(gc.Request.Context(), cmd)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusCreated, evento)
+}
+
+func (s *servidor) reemplazarEvento(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd EventoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	evento, err := s.eventos.ReemplazarEvento(gc.Request.Context(), id, cmd)
+	if err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, evento)
+}
+
+func (s *servidor) eliminarEvento(gc *gin.Context) {
+	id := gc.Param("id")
+
+	if err := s.eventos.EliminarEvento(gc.Request.Context(), id); err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}
+
+// getInvitadosDeEvento lista a los invitados al evento con su respuesta a ese
+// evento en Asiste.
+func (s *servidor) getInvitadosDeEvento(gc *gin.Context) {
+	id := gc.Param("id")
+
+	invitados, err := s.eventos.InvitadosDeEvento(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitados)
+}
+
+func (s *servidor) invitarAEvento(gc *gin.Context) {
+	id := gc.Param("id")
+
+	if err := s.eventos.InvitarAEvento(gc.Request.Context(), id, gc.Param("invitadoId")); err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}
+
+func (s *servidor) quitarDeEvento(gc *gin.Context) {
+	id := gc.Param("id")
+
+	if err := s.eventos.QuitarDeEvento(gc.Request.Context(), id, gc.Param("invitadoId")); err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}
+
+func (s *servidor) getEventosDeInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+
+	eventos, err := s.eventos.EventosDeInvitado(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, eventos)
+}
+
+func (s *servidor) aceptarEvento(gc *gin.Context) {
+	s.responderAsistenciaEvento(gc, true)
+}
+
+func (s *servidor) rechazarEvento(gc *gin.Context) {
+	s.responderAsistenciaEvento(gc, false)
+}
+
+// errorEvento traduce los errores del store de eventos; los demás quedan como
+// internos.
+func errorEvento(id string, err error) error {
+	switch {
+	case errors.Is(err, errEventoNoEncontrado):
+		return noEncontrado(fmt.Sprintf("No se encontró un evento con el id %v", id))
+	case errors.Is(err, errInvitadoNoEncontrado):
+		return noEncontrado("No se encontró el invitado")
+	case errors.Is(err, errNoInvitadoAEvento):
+		return noEncontrado(fmt.Sprintf("El invitado no está invitado al evento %v", id))
+	default:
+		return err
+	}
+}
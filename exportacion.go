@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	formatoCSV  = "csv"
+	formatoXLSX = "xlsx"
+	formatoJSON = "json"
+)
+
+var tiposContenidoExportacion = map[string]string{
+	formatoCSV:  "text/csv; charset=utf-8",
+	formatoXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	formatoJSON: "application/json; charset=utf-8",
+}
+
+// filaRsvp es la fila que se entrega a proveedores: un invitado con su familia
+// y el estado de su respuesta.
+type filaRsvp struct {
+	Nombre            string `json:"nombre"`
+	Nombre_invitacion string `json:"nombre_invitacion"`
+	Familia           string `json:"familia"`
+	Asistencia        string `json:"asistencia"`
+}
+
+var encabezadosRsvp = []string{"nombre", "nombre_invitacion", "familia", "asistencia"}
+
+func (fila filaRsvp) valores() []string {
+	return []string{fila.Nombre, fila.Nombre_invitacion, fila.Familia, fila.Asistencia}
+}
+
+// filasRsvp arma las filas del reporte. Con asistencia vacía incluye a todos;
+// si no, solo a quienes tengan ese estado (sin-respuesta, si-asiste, no-asiste).
+func filasRsvp(invitados []InvitadoFamilia, asistencia string) []filaRsvp {
+	filas := make([]filaRsvp, 0, len(invitados))
+	for _, inv := range invitados {
+		fila := filaRsvp{
+			Nombre:            inv.Nombre,
+			Nombre_invitacion: inv.Nombre_invitacion,
+			Familia:           inv.Nombre_familia.String,
+			Asistencia:        getClassAsisteByInv(inv.Asiste),
+		}
+		if asistencia != "" && fila.Asistencia != asistencia {
+			continue
+		}
+		filas = append(filas, fila)
+	}
+	return filas
+}
+
+func escribirRsvp(w io.Writer, formato string, filas []filaRsvp) error {
+	switch formato {
+	case formatoXLSX:
+		return escribirRsvpXLSX(w, filas)
+	case formatoJSON:
+		codificador := json.NewEncoder(w)
+		codificador.SetIndent("", "    ")
+		return codificador.Encode(filas)
+	default:
+		return escribirRsvpCSV(w, filas)
+	}
+}
+
+func escribirRsvpCSV(w io.Writer, filas []filaRsvp) error {
+	escritor := csv.NewWriter(w)
+
+	if err := escritor.Write(encabezadosRsvp); err != nil {
+		return fmt.Errorf("escribirRsvpCSV %s", err)
+	}
+	for _, fila := range filas {
+		if err := escritor.Write(fila.valores()); err != nil {
+			return fmt.Errorf("escribirRsvpCSV %s", err)
+		}
+	}
+
+	escritor.Flush()
+	return escritor.Error()
+}
+
+func escribirRsvpXLSX(w io.Writer, filas []filaRsvp) error {
+	libro := excelize.NewFile()
+	defer libro.Close()
+
+	hoja := libro.GetSheetName(0)
+	if err := libro.SetSheetRow(hoja, "A1", &encabezadosRsvp); err != nil {
+		return fmt.Errorf("escribirRsvpXLSX %s", err)
+	}
+	for i, fila := range filas {
+		celda, err := excelize.CoordinatesToCellName(1, i+2)
+		if err != nil {
+			return fmt.Errorf("escribirRsvpXLSX %s", err)
+		}
+		valores := fila.valores()
+		if err := libro.SetSheetRow(hoja, celda, &valores); err != nil {
+			return fmt.Errorf("escribirRsvpXLSX %s", err)
+		}
+	}
+
+	return libro.Write(w)
+}
+
+// formatoPorArchivo elige el formato según la extensión del archivo de salida.
+func formatoPorArchivo(nombre string) string {
+	switch {
+	case strings.HasSuffix(strings.ToLower(nombre), ".xlsx"):
+		return formatoXLSX
+	case strings.HasSuffix(strings.ToLower(nombre), ".json"):
+		return formatoJSON
+	default:
+		return formatoCSV
+	}
+}
+
+// formatoPorPeticion usa ?format= y, si no viene, el encabezado Accept. Por
+// defecto entrega CSV.
+func formatoPorPeticion(gc *gin.Context) (string, bool) {
+	if formato := strings.ToLower(gc.Query("format")); formato != "" {
+		_, ok := tiposContenidoExportacion[formato]
+		return formato, ok
+	}
+
+	switch gc.NegotiateFormat("text/csv", gin.MIMEJSON, tiposContenidoExportacion[formatoXLSX]) {
+	case tiposContenidoExportacion[formatoXLSX]:
+		return formatoXLSX, true
+	case gin.MIMEJSON:
+		return formatoJSON, true
+	default:
+		return formatoCSV, true
+	}
+}
+
+func (s *servidor) exportarRsvp(gc *gin.Context) {
+	formato, ok := formatoPorPeticion(gc)
+	if !ok {
+		s.responderError(gc, entradaInvalida("Formato desconocido, usa csv, xlsx o json"))
+		return
+	}
+
+	invitados, err := s.invitados.InvitadosConFamilia(gc.Request.Context())
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	filas := filasRsvp(invitados, gc.Query("asistencia"))
+
+	gc.Header("Content-Type", tiposContenidoExportacion[formato])
+	gc.Header("Content-Disposition", fmt.Sprintf("attachment; filename=rsvp.%s", formato))
+	gc.Status(http.StatusOK)
+	if err := escribirRsvp(gc.Writer, formato, filas); err != nil {
+		gc.Error(err)
+	}
+}
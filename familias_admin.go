@@ -0,0 +1,157 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+func (cmd *FamiliasCommand) normalizar() {
+	cmd.Nombre = strings.TrimSpace(cmd.Nombre)
+	cmd.Nombre_invitacion = strings.TrimSpace(cmd.Nombre_invitacion)
+}
+
+func (cmd FamiliasCommand) validar() error {
+	if cmd.Nombre == "" {
+		return errors.New("el nombre es obligatorio")
+	}
+	if cmd.Nombre_invitacion == "" {
+		return errors.New("el nombre de la invitación es obligatorio")
+	}
+	if cmd.Miembro_principal <= 0 {
+		return errors.New("el miembro principal es obligatorio")
+	}
+	if utf8.RuneCountInString(cmd.Nombre) > largoMaximoNombre {
+		return fmt.Errorf("el nombre no puede superar %v caracteres", largoMaximoNombre)
+	}
+	if utf8.RuneCountInString(cmd.Nombre_invitacion) > largoMaximoNombre {
+		return fmt.Errorf("el nombre de la invitación no puede superar %v caracteres", largoMaximoNombre)
+	}
+	return nil
+}
+
+func (s *servidor) crearFamilia(gc *gin.Context) {
+	var cmd FamiliasCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	familia, err := s.familias.CrearFamilia(gc.Request.Context(), cmd)
+	if err != nil {
+		s.responderError(gc, errorFamilia("", err))
+		return
+	}
+
+	s.json(gc, http.StatusCreated, familia)
+}
+
+func (s *servidor) reemplazarFamilia(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd FamiliasCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	familia, err := s.familias.ReemplazarFamilia(gc.Request.Context(), id, cmd)
+	if err != nil {
+		s.responderError(gc, errorFamilia(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, familia)
+}
+
+func (s *servidor) eliminarFamilia(gc *gin.Context) {
+	id := gc.Param("id")
+	cascada := gc.Query("cascada") == "true"
+
+	if err := s.familias.EliminarFamilia(gc.Request.Context(), id, cascada); err != nil {
+		s.responderError(gc, errorFamilia(id, err))
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}
+
+func (s *servidor) agregarInvitadoAFamilia(gc *gin.Context) {
+	id := gc.Param("id")
+
+	invitado, err := s.familias.MoverInvitado(gc.Request.Context(), gc.Param("invitadoId"), id)
+	if err != nil {
+		s.responderError(gc, errorFamilia(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitado)
+}
+
+func (s *servidor) quitarInvitadoDeFamilia(gc *gin.Context) {
+	id := gc.Param("id")
+	invitadoId := gc.Param("invitadoId")
+
+	invitados, err := s.invitados.InvitadosPorFamilia(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorFamilia(id, err))
+		return
+	}
+
+	pertenece := false
+	for _, invitado := range invitados {
+		if invitado.Id_text == invitadoId {
+			pertenece = true
+		}
+	}
+	if !pertenece {
+		s.responderError(gc, noEncontrado(fmt.Sprintf("El invitado %v no pertenece a la familia %v", invitadoId, id)))
+		return
+	}
+
+	invitado, err := s.familias.MoverInvitado(gc.Request.Context(), invitadoId, "")
+	if err != nil {
+		s.responderError(gc, errorFamilia(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitado)
+}
+
+// errorFamilia traduce los errores del store de familias; los demás quedan
+// como internos.
+func errorFamilia(id string, err error) error {
+	switch {
+	case errors.Is(err, errFamiliaNoEncontrada):
+		return noEncontrado(fmt.Sprintf("No se encontró una familia con el id %v", id))
+	case errors.Is(err, errInvitadoNoEncontrado):
+		return noEncontrado("No se encontró el invitado")
+	case errors.Is(err, errMiembroPrincipalInvalido):
+		return entradaInvalida("El miembro principal no corresponde a ningún invitado")
+	case errors.Is(err, errMiembroPrincipalDeOtraFamilia):
+		return conflicto("El miembro principal ya encabeza otra familia")
+	case errors.Is(err, errInvitadoEsPrincipal):
+		return conflicto("El invitado es el miembro principal de su familia y no puede cambiar de familia")
+	case errors.Is(err, errFamiliaConMiembros):
+		return conflicto(fmt.Sprintf("La familia %v todavía tiene invitados, usa ?cascada=true para eliminarlos también", id))
+	default:
+		return err
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Acciones que puede autorizar un enlace firmado.
+const (
+	alcanceRsvp    = "rsvp"
+	alcanceCancion = "cancion"
+	alcanceMensaje = "mensaje"
+)
+
+var alcancesEnlace = []string{alcanceRsvp, alcanceCancion, alcanceMensaje}
+
+// prefijoEnlaceFirmado distingue un enlace firmado de un token de la base, que
+// es hexadecimal y nunca lleva "_".
+const prefijoEnlaceFirmado = "f1_"
+
+const largoMinimoClaveFirma = 32
+
+const claveEnlaceFirmadoCtx = "enlaceFirmado"
+
+var errEnlaceInvalido = errors.New("el enlace no es válido o expiró")
+var errEnlaceSinAlcance = errors.New("el enlace no permite esta acción")
+
+// contenidoEnlace es lo que va firmado dentro del enlace.
+type contenidoEnlace struct {
+	Clave    string   `json:"k"`
+	Tipo     string   `json:"t"`
+	Id_text  string   `json:"i"`
+	Alcances []string `json:"a"`
+	Expira   int64    `json:"e,omitempty"`
+}
+
+type claveFirma struct {
+	id      string
+	secreto []byte
+}
+
+// firmador firma enlaces con la primera clave y verifica con cualquiera de
+// ellas. Quitar una clave invalida de golpe todos los enlaces firmados con
+// ella, sin tocar la base.
+type firmador struct {
+	claves []claveFirma
+}
+
+// nuevoFirmador lee claves con el formato "id:secreto,id:secreto". Sin
+// claves los enlaces firmados quedan deshabilitados.
+func nuevoFirmador(spec string) (*firmador, error) {
+	f := &firmador{}
+	for _, parte := range strings.Split(spec, ",") {
+		parte = strings.TrimSpace(parte)
+		if parte == "" {
+			continue
+		}
+		id, secreto, ok := strings.Cut(parte, ":")
+		if !ok || id == "" {
+			return nil, fmt.Errorf("clave de firma inválida %q, usa id:secreto", parte)
+		}
+		if len(secreto) < largoMinimoClaveFirma {
+			return nil, fmt.Errorf("el secreto de la clave %q debe tener al menos %v caracteres", id, largoMinimoClaveFirma)
+		}
+		f.claves = append(f.claves, claveFirma{id: id, secreto: []byte(secreto)})
+	}
+	return f, nil
+}
+
+func (f *firmador) habilitado() bool {
+	return f != nil && len(f.claves) > 0
+}
+
+func esEnlaceFirmado(credencial string) bool {
+	return strings.HasPrefix(credencial, prefijoEnlaceFirmado)
+}
+
+func validarAlcances(alcances []string) error {
+	if len(alcances) == 0 {
+		return errors.New("indica al menos un alcance")
+	}
+	for _, alcance := range alcances {
+		valido := false
+		for _, a := range alcancesEnlace {
+			valido = valido || a == alcance
+		}
+		if !valido {
+			return fmt.Errorf("alcance desconocido %q, usa %s", alcance, strings.Join(alcancesEnlace, ", "))
+		}
+	}
+	return nil
+}
+
+// firmar arma el enlace: el JSON del contenido seguido de su HMAC-SHA256, todo
+// en base64 para URL, que también sirve como id de HTML.
+func (f *firmador) firmar(tipo string, idText string, alcances []string, expira time.Time) (string, error) {
+	if !f.habilitado() {
+		return "", errors.New("no hay claves de firma, define FIRMAENLACES")
+	}
+	if err := validarAlcances(alcances); err != nil {
+		return "", err
+	}
+
+	contenido := contenidoEnlace{Clave: f.claves[0].id, Tipo: tipo, Id_text: idText, Alcances: alcances}
+	if !expira.IsZero() {
+		contenido.Expira = expira.Unix()
+	}
+
+	datos, err := json.Marshal(contenido)
+	if err != nil {
+		return "", fmt.Errorf("firmar %s", err)
+	}
+
+	mac := hmac.New(sha256.New, f.claves[0].secreto)
+	mac.Write(datos)
+	// Sum agrega la firma al final de datos.
+	firmado := mac.Sum(datos)
+
+	return prefijoEnlaceFirmado + base64.RawURLEncoding.EncodeToString(firmado), nil
+}
+
+// verificar comprueba la firma y la expiración del enlace y, si alcance no
+// está vacío, que lo permita.
+func (f *firmador) verificar(enlace string, alcance string) (Invitacion, error) {
+	if !f.habilitado() || !esEnlaceFirmado(enlace) {
+		return Invitacion{}, errEnlaceInvalido
+	}
+
+	crudo, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(enlace, prefijoEnlaceFirmado))
+	if err != nil || len(crudo) <= sha256.Size {
+		return Invitacion{}, errEnlaceInvalido
+	}
+	datos, firma := crudo[:len(crudo)-sha256.Size], crudo[len(crudo)-sha256.Size:]
+
+	var contenido contenidoEnlace
+	if err := json.Unmarshal(datos, &contenido); err != nil {
+		return Invitacion{}, errEnlaceInvalido
+	}
+
+	valida := false
+	for _, clave := range f.claves {
+		if clave.id != contenido.Clave {
+			continue
+		}
+		mac := hmac.New(sha256.New, clave.secreto)
+		mac.Write(datos)
+		valida = hmac.Equal(firma, mac.Sum(nil))
+	}
+	if !valida {
+		return Invitacion{}, errEnlaceInvalido
+	}
+
+	invitacion := Invitacion{Token: enlace, Tipo: contenido.Tipo, Id_text: contenido.Id_text}
+	if contenido.Expira != 0 {
+		expira := time.Unix(contenido.Expira, 0).UTC()
+		if !time.Now().Before(expira) {
+			return Invitacion{}, errEnlaceInvalido
+		}
+		invitacion.Expira_en = &expira
+	}
+
+	if alcance == "" {
+		return invitacion, nil
+	}
+	for _, a := range contenido.Alcances {
+		if a == alcance {
+			return invitacion, nil
+		}
+	}
+	return Invitacion{}, errEnlaceSinAlcance
+}
+
+// maxCuerpoInvitado es lo más que se lee del cuerpo de las rutas de los
+// invitados; ninguna respuesta legítima se acerca.
+const maxCuerpoInvitado = 16 << 10
+
+// credencialDePeticion toma el enlace del campo invitado_id del cuerpo JSON,
+// que se deja intacto para el handler, o, si no viene, del parámetro :id. El
+// cuerpo va primero porque en /eventos/:id/asistencia el :id es el evento. Un
+// cuerpo de más de maxCuerpoInvitado no se lee entero: el handler recibe el
+// error al leerlo y responde 400.
+func credencialDePeticion(gc *gin.Context) string {
+	if gc.Request.Body != nil {
+		lector := http.MaxBytesReader(gc.Writer, gc.Request.Body, maxCuerpoInvitado)
+		cuerpo, err := io.ReadAll(lector)
+		gc.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(cuerpo), lector))
+
+		var invitado InvitadoId
+		if err == nil && json.Unmarshal(cuerpo, &invitado) == nil && invitado.Invitado_Id != "" {
+			return invitado.Invitado_Id
+		}
+	}
+	return gc.Param("id")
+}
+
+// requiereAlcance revisa los enlaces firmados antes del handler: si la firma
+// no es válida o no incluye el alcance la petición se corta sin consultar la
+// base. Los tokens normales pasan y los resuelve el handler.
+func (s *servidor) requiereAlcance(alcance string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		credencial := credencialDePeticion(gc)
+		if !esEnlaceFirmado(credencial) {
+			gc.Next()
+			return
+		}
+
+		invitacion, err := s.firmas.verificar(credencial, alcance)
+		if errors.Is(err, errEnlaceSinAlcance) {
+			s.responderError(gc, &errorApi{Tipo: tipoProhibido, Mensaje: "Este enlace no permite esta acción"})
+			return
+		}
+		if err != nil {
+			s.responderError(gc, noEncontrado("El enlace no es válido o expiró"))
+			return
+		}
+
+		gc.Set(claveEnlaceFirmadoCtx, invitacion)
+		gc.Next()
+	}
+}
+
+type EnlaceCommand struct {
+	Alcances  []string   `json:"alcances"`
+	Expira_en *time.Time `json:"expira_en"`
+}
+
+type EnlaceResp struct {
+	Enlace   string   `json:"enlace"`
+	Alcances []string `json:"alcances"`
+}
+
+// crearEnlaceFirmado firma un enlace para el invitado o la familia :id. Sin
+// alcances en el cuerpo el enlace permite todas las acciones.
+func (s *servidor) crearEnlaceFirmado(tipo string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		id := gc.Param("id")
+		var cmd EnlaceCommand
+
+		if gc.Request.ContentLength != 0 {
+			if err := gc.ShouldBindJSON(&cmd); err != nil {
+				s.responderError(gc, peticionIncorrecta(err))
+				return
+			}
+		}
+		if len(cmd.Alcances) == 0 {
+			cmd.Alcances = alcancesEnlace
+		}
+
+		var expira time.Time
+		if cmd.Expira_en != nil {
+			if !cmd.Expira_en.After(time.Now()) {
+				s.responderError(gc, entradaInvalida("La fecha de expiración debe ser futura"))
+				return
+			}
+			expira = *cmd.Expira_en
+		}
+
+		// Se firma solo lo que existe, aunque después el enlace se verifique
+		// sin consultar la base, y nunca para un acompañante.
+		var err error
+		if tipo == invitacionFamilia {
+			_, err = s.familias.FamiliaPorId(gc.Request.Context(), id)
+		} else {
+			var invitado InvitadoResp
+			invitado, err = s.invitados.InvitadoPorId(gc.Request.Context(), id)
+			if err == nil && invitado.Anfitrion != nil {
+				err = errInvitadoEsAcompanante
+			}
+		}
+		if err != nil {
+			s.responderError(gc, errorInvitacion(id, err))
+			return
+		}
+
+		enlace, err := s.firmas.firmar(tipo, id, cmd.Alcances, expira)
+		if err != nil {
+			s.responderError(gc, entradaInvalida(err.Error()))
+			return
+		}
+
+		s.json(gc, http.StatusCreated, EnlaceResp{Enlace: enlace, Alcances: cmd.Alcances})
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	secretoPrueba       = "secreto-de-prueba-con-32-caracteres"
+	otroSecretoPrueba   = "otro-secreto-de-prueba-con-32-caract"
+	idInvitadoPrueba    = "abc123defg"
+	claveActualPrueba   = "k2:" + otroSecretoPrueba
+	claveAnteriorPrueba = "k1:" + secretoPrueba
+)
+
+func TestNuevoFirmador(t *testing.T) {
+	casos := []struct {
+		nombre     string
+		spec       string
+		habilitado bool
+		falla      bool
+	}{
+		{"vacío", "", false, false},
+		{"una clave", claveAnteriorPrueba, true, false},
+		{"dos claves con espacios", claveActualPrueba + " , " + claveAnteriorPrueba, true, false},
+		{"sin id", ":" + secretoPrueba, false, true},
+		{"sin separador", secretoPrueba, false, true},
+		{"secreto corto", "k1:corto", false, true},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			f, err := nuevoFirmador(c.spec)
+			if (err != nil) != c.falla {
+				t.Fatalf("nuevoFirmador(%q) error = %v, se esperaba falla %v", c.spec, err, c.falla)
+			}
+			if err == nil && f.habilitado() != c.habilitado {
+				t.Errorf("habilitado() = %v, se esperaba %v", f.habilitado(), c.habilitado)
+			}
+		})
+	}
+}
+
+func TestFirmarYVerificar(t *testing.T) {
+	anterior, err := nuevoFirmador(claveAnteriorPrueba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotado, err := nuevoFirmador(claveActualPrueba + "," + claveAnteriorPrueba)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sinAnterior, err := nuevoFirmador(claveActualPrueba)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	futuro := time.Now().Add(time.Hour)
+	pasado := time.Now().Add(-time.Hour)
+
+	firmar := func(f *firmador, alcances []string, expira time.Time) string {
+		t.Helper()
+		enlace, err := f.firmar(invitacionInvitado, idInvitadoPrueba, alcances, expira)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return enlace
+	}
+	vigente := firmar(anterior, []string{alcanceRsvp}, futuro)
+
+	// alterado cambia un carácter del contenido, antes de la firma.
+	alterado := []byte(vigente)
+	i := len(prefijoEnlaceFirmado) + 4
+	if alterado[i] == 'A' {
+		alterado[i] = 'B'
+	} else {
+		alterado[i] = 'A'
+	}
+
+	casos := []struct {
+		nombre      string
+		verificador *firmador
+		enlace      string
+		alcance     string
+		err         error
+	}{
+		{"vigente", anterior, vigente, alcanceRsvp, nil},
+		{"sin alcance pedido", anterior, vigente, "", nil},
+		{"sin expiración", anterior, firmar(anterior, alcancesEnlace, time.Time{}), alcanceMensaje, nil},
+		{"alcance no incluido", anterior, vigente, alcanceCancion, errEnlaceSinAlcance},
+		{"expirado", anterior, firmar(anterior, []string{alcanceRsvp}, pasado), alcanceRsvp, errEnlaceInvalido},
+		{"contenido alterado", anterior, string(alterado), alcanceRsvp, errEnlaceInvalido},
+		{"firma cortada", anterior, vigente[:len(vigente)-4], alcanceRsvp, errEnlaceInvalido},
+		{"sin prefijo", anterior, strings.TrimPrefix(vigente, prefijoEnlaceFirmado), alcanceRsvp, errEnlaceInvalido},
+		{"base64 inválido", anterior, prefijoEnlaceFirmado + "***", alcanceRsvp, errEnlaceInvalido},
+		{"clave anterior tras rotar", rotado, vigente, alcanceRsvp, nil},
+		{"clave nueva tras rotar", rotado, firmar(rotado, []string{alcanceRsvp}, futuro), alcanceRsvp, nil},
+		{"clave quitada", sinAnterior, vigente, alcanceRsvp, errEnlaceInvalido},
+		{"sin claves", &firmador{}, vigente, alcanceRsvp, errEnlaceInvalido},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			invitacion, err := c.verificador.verificar(c.enlace, c.alcance)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("verificar() error = %v, se esperaba %v", err, c.err)
+			}
+			if err != nil {
+				return
+			}
+			if invitacion.Tipo != invitacionInvitado || invitacion.Id_text != idInvitadoPrueba || invitacion.Token != c.enlace {
+				t.Errorf("verificar() = %+v, no corresponde al enlace firmado", invitacion)
+			}
+		})
+	}
+}
+
+func TestFirmarRechazaAlcances(t *testing.T) {
+	f, err := nuevoFirmador(claveAnteriorPrueba)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre   string
+		firmador *firmador
+		alcances []string
+	}{
+		{"sin alcances", f, nil},
+		{"alcance desconocido", f, []string{alcanceRsvp, "admin"}},
+		{"sin claves", &firmador{}, []string{alcanceRsvp}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			if enlace, err := c.firmador.firmar(invitacionInvitado, idInvitadoPrueba, c.alcances, time.Time{}); err == nil {
+				t.Errorf("firmar() = %q, se esperaba un error", enlace)
+			}
+		})
+	}
+}
+
+func TestEnlaceFirmadoAcompanante(t *testing.T) {
+	store := storePrueba(t)
+	anfitrion, acompanante := acompanantePrueba(t, store)
+	s, router := servidorPrueba(store)
+	router.POST("/invitados/:id/enlace", s.crearEnlaceFirmado(invitacionInvitado))
+
+	var err error
+	if s.firmas, err = nuevoFirmador(claveAnteriorPrueba); err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre string
+		idText string
+		estado int
+	}{
+		{"anfitrión", anfitrion, http.StatusCreated},
+		{"acompañante", acompanante, http.StatusConflict},
+		{"desconocido", "noexiste00", http.StatusNotFound},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			respuesta := httptest.NewRecorder()
+			router.ServeHTTP(respuesta, httptest.NewRequest(http.MethodPost, "/invitados/"+c.idText+"/enlace", nil))
+			if respuesta.Code != c.estado {
+				t.Errorf("POST = %v, se esperaba %v: %s", respuesta.Code, c.estado, respuesta.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// filaImportacion es una fila de la lista de invitados. Solo el nombre es
+// obligatorio; el id_text sirve para reconocer invitados ya cargados.
+type filaImportacion struct {
+	Linea             int      `json:"linea,omitempty"`
+	Id_text           string   `json:"id_text,omitempty"`
+	Nombre            string   `json:"nombre"`
+	Nombre_invitacion string   `json:"nombre_invitacion"`
+	Familia           string   `json:"familia,omitempty"`
+	Miembro_principal bool     `json:"miembro_principal,omitempty"`
+	Cambios           []string `json:"cambios,omitempty"`
+}
+
+// planImportacion es la diferencia entre el archivo y la base. Se muestra tal
+// cual en el modo de prueba y se aplica completo o nada.
+type planImportacion struct {
+	Nuevos          []filaImportacion `json:"nuevos"`
+	Cambiados       []filaImportacion `json:"cambiados"`
+	Sin_cambios     int               `json:"sin_cambios"`
+	Eliminados      []filaImportacion `json:"eliminados"`
+	Familias_nuevas []string          `json:"familias_nuevas"`
+
+	// Con_familias indica si el archivo trae la columna familia. Sin ella no
+	// se toca a qué familia pertenece cada invitado.
+	Con_familias bool `json:"con_familias"`
+	// filas son todas las filas del archivo con su id_text ya resuelto para
+	// los invitados existentes.
+	filas []filaImportacion
+}
+
+var columnasImportacion = []string{"id_text", "nombre", "nombre_invitacion", "familia", "miembro_principal"}
+
+// leerArchivoInvitados lee un CSV o, si el nombre termina en .xlsx, la primera
+// hoja de un libro de Excel.
+func leerArchivoInvitados(nombreArchivo string, r io.Reader) ([]filaImportacion, bool, error) {
+	if strings.HasSuffix(strings.ToLower(nombreArchivo), ".xlsx") {
+		return leerXLSXInvitados(r)
+	}
+	return leerCSVInvitados(r)
+}
+
+// leerCSVInvitados lee un CSV con encabezado. Las columnas se reconocen por
+// nombre sin importar el orden ni las mayúsculas.
+func leerCSVInvitados(r io.Reader) ([]filaImportacion, bool, error) {
+	lector := csv.NewReader(r)
+	lector.TrimLeadingSpace = true
+	lector.FieldsPerRecord = -1
+
+	registros, err := lector.ReadAll()
+	if err != nil {
+		return nil, false, fmt.Errorf("leerCSVInvitados %s", err)
+	}
+
+	return filasDesdeRegistros(registros)
+}
+
+func leerXLSXInvitados(r io.Reader) ([]filaImportacion, bool, error) {
+	libro, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, false, fmt.Errorf("leerXLSXInvitados %s", err)
+	}
+	defer libro.Close()
+
+	registros, err := libro.GetRows(libro.GetSheetName(0))
+	if err != nil {
+		return nil, false, fmt.Errorf("leerXLSXInvitados %s", err)
+	}
+
+	return filasDesdeRegistros(registros)
+}
+
+// filasDesdeRegistros convierte los registros en filas y devuelve además si el
+// archivo trae la columna familia.
+func filasDesdeRegistros(registros [][]string) ([]filaImportacion, bool, error) {
+	if len(registros) == 0 {
+		return nil, false, errors.New("el archivo está vacío")
+	}
+
+	indices := map[string]int{}
+	for i, columna := range registros[0] {
+		indices[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(columna, "\ufeff")))] = i
+	}
+	if _, ok := indices["nombre"]; !ok {
+		return nil, false, fmt.Errorf("falta la columna nombre, las columnas reconocidas son %s", strings.Join(columnasImportacion, ", "))
+	}
+	_, conFamilias := indices["familia"]
+
+	valor := func(registro []string, columna string) string {
+		i, ok := indices[columna]
+		if !ok || i >= len(registro) {
+			return ""
+		}
+		return strings.TrimSpace(registro[i])
+	}
+
+	var filas []filaImportacion
+	for n, registro := range registros[1:] {
+		fila := filaImportacion{
+			Linea:             n + 2,
+			Id_text:           valor(registro, "id_text"),
+			Nombre:            valor(registro, "nombre"),
+			Nombre_invitacion: valor(registro, "nombre_invitacion"),
+			Familia:           valor(registro, "familia"),
+			Miembro_principal: esAfirmativo(valor(registro, "miembro_principal")),
+		}
+
+		if fila.Nombre == "" && fila.Id_text == "" {
+			continue
+		}
+		if fila.Nombre_invitacion == "" {
+			fila.Nombre_invitacion = fila.Nombre
+		}
+
+		cmd := InvitadoCommand{Nombre: fila.Nombre, Nombre_invitacion: fila.Nombre_invitacion}
+		if err := cmd.validar(); err != nil {
+			return nil, false, fmt.Errorf("línea %v: %s", fila.Linea, err)
+		}
+
+		filas = append(filas, fila)
+	}
+
+	return filas, conFamilias, nil
+}
+
+func esAfirmativo(valor string) bool {
+	switch strings.ToLower(valor) {
+	case "si", "sí", "s", "x", "1", "true":
+		return true
+	}
+	return false
+}
+
+func claveNombre(nombre string) string {
+	return strings.ToLower(strings.Join(strings.Fields(nombre), " "))
+}
+
+// planificarImportacion compara las filas con los invitados actuales. Cada fila
+// se empareja por id_text o, si no lo trae, por nombre; los invitados que no
+// aparecen en el archivo quedan como eliminados.
+func planificarImportacion(filas []filaImportacion, conFamilias bool, actuales []InvitadoFamilia, familias []FamiliasResp) (planImportacion, error) {
+	plan := planImportacion{
+		Nuevos:          []filaImportacion{},
+		Cambiados:       []filaImportacion{},
+		Eliminados:      []filaImportacion{},
+		Familias_nuevas: []string{},
+		Con_familias:    conFamilias,
+	}
+
+	porIdText := map[string]InvitadoFamilia{}
+	porNombre := map[string][]InvitadoFamilia{}
+	for _, inv := range actuales {
+		porIdText[inv.Id_text] = inv
+		porNombre[claveNombre(inv.Nombre)] = append(porNombre[claveNombre(inv.Nombre)], inv)
+	}
+
+	principales := map[string]int64{}
+	familiasExistentes := map[string]bool{}
+	for _, familia := range familias {
+		principales[familia.Id_text] = familia.Miembro_principal
+		familiasExistentes[claveNombre(familia.Nombre)] = true
+	}
+
+	emparejados := map[string]int{}
+	familiasNuevas := map[string]bool{}
+
+	for _, fila := range filas {
+		var actual InvitadoFamilia
+		encontrado := false
+
+		if fila.Id_text != "" {
+			actual, encontrado = porIdText[fila.Id_text]
+			if !encontrado {
+				return plan, fmt.Errorf("línea %v: no existe un invitado con el id_text %v", fila.Linea, fila.Id_text)
+			}
+		} else {
+			var candidatos []InvitadoFamilia
+			for _, inv := range porNombre[claveNombre(fila.Nombre)] {
+				if _, usado := emparejados[inv.Id_text]; !usado {
+					candidatos = append(candidatos, inv)
+				}
+			}
+			if len(candidatos) > 1 {
+				return plan, fmt.Errorf("línea %v: hay %v invitados llamados %v, agrega la columna id_text para distinguirlos", fila.Linea, len(candidatos), fila.Nombre)
+			}
+			if len(candidatos) == 1 {
+				actual, encontrado = candidatos[0], true
+			}
+		}
+
+		if conFamilias && fila.Familia != "" && !familiasExistentes[claveNombre(fila.Familia)] && !familiasNuevas[claveNombre(fila.Familia)] {
+			familiasNuevas[claveNombre(fila.Familia)] = true
+			plan.Familias_nuevas = append(plan.Familias_nuevas, fila.Familia)
+		}
+
+		if !encontrado {
+			plan.Nuevos = append(plan.Nuevos, fila)
+			plan.filas = append(plan.filas, fila)
+			continue
+		}
+
+		if lineaPrevia, usado := emparejados[actual.Id_text]; usado {
+			return plan, fmt.Errorf("línea %v: el invitado %v ya aparece en la línea %v", fila.Linea, actual.Id_text, lineaPrevia)
+		}
+		emparejados[actual.Id_text] = fila.Linea
+		fila.Id_text = actual.Id_text
+
+		if fila.Nombre != actual.Nombre {
+			fila.Cambios = append(fila.Cambios, fmt.Sprintf("nombre: %q → %q", actual.Nombre, fila.Nombre))
+		}
+		if fila.Nombre_invitacion != actual.Nombre_invitacion {
+			fila.Cambios = append(fila.Cambios, fmt.Sprintf("nombre_invitacion: %q → %q", actual.Nombre_invitacion, fila.Nombre_invitacion))
+		}
+		cambiaDeFamilia := conFamilias && claveNombre(fila.Familia) != claveNombre(actual.Nombre_familia.String)
+		if cambiaDeFamilia {
+			fila.Cambios = append(fila.Cambios, fmt.Sprintf("familia: %q → %q", actual.Nombre_familia.String, fila.Familia))
+		}
+		esPrincipal := !cambiaDeFamilia && principales[actual.Id_text_familia.String] == actual.Id
+		if conFamilias && fila.Miembro_principal && fila.Familia != "" && !esPrincipal {
+			fila.Cambios = append(fila.Cambios, "ahora es el miembro principal")
+		}
+
+		plan.filas = append(plan.filas, fila)
+		if len(fila.Cambios) > 0 {
+			plan.Cambiados = append(plan.Cambiados, fila)
+		} else {
+			plan.Sin_cambios++
+		}
+	}
+
+	for _, inv := range actuales {
+		if _, ok := emparejados[inv.Id_text]; !ok {
+			plan.Eliminados = append(plan.Eliminados, filaImportacion{
+				Id_text:           inv.Id_text,
+				Nombre:            inv.Nombre,
+				Nombre_invitacion: inv.Nombre_invitacion,
+				Familia:           inv.Nombre_familia.String,
+			})
+		}
+	}
+
+	return plan, nil
+}
+
+// imprimirPlan muestra el plan como un diff: + nuevos, ~ cambiados, - eliminados.
+func imprimirPlan(w io.Writer, plan planImportacion, eliminar bool) {
+	for _, fila := range plan.Nuevos {
+		fmt.Fprintf(w, "+ %s (%s)", fila.Nombre, fila.Nombre_invitacion)
+		if fila.Familia != "" {
+			fmt.Fprintf(w, " en %s", fila.Familia)
+		}
+		fmt.Fprintln(w)
+	}
+	for _, fila := range plan.Cambiados {
+		fmt.Fprintf(w, "~ %s [%s]\n", fila.Nombre, fila.Id_text)
+		for _, cambio := range fila.Cambios {
+			fmt.Fprintf(w, "    %s\n", cambio)
+		}
+	}
+	for _, fila := range plan.Eliminados {
+		fmt.Fprintf(w, "- %s [%s]\n", fila.Nombre, fila.Id_text)
+	}
+	for _, familia := range plan.Familias_nuevas {
+		fmt.Fprintf(w, "+ familia %s\n", familia)
+	}
+
+	fmt.Fprintf(w, "nuevos: %v, cambiados: %v, sin cambios: %v, eliminados: %v, familias nuevas: %v\n",
+		len(plan.Nuevos), len(plan.Cambiados), plan.Sin_cambios, len(plan.Eliminados), len(plan.Familias_nuevas))
+	if len(plan.Eliminados) > 0 && !eliminar {
+		fmt.Fprintln(w, "los invitados marcados con - solo se eliminan con --eliminar")
+	}
+}
+
+// prepararImportacion calcula el plan contra el estado actual de la base.
+func prepararImportacion(ctx context.Context, invitados GuestStore, familias FamilyStore, filas []filaImportacion, conFamilias bool) (planImportacion, error) {
+	actuales, err := invitados.InvitadosConFamilia(ctx)
+	if err != nil {
+		return planImportacion{}, err
+	}
+
+	listaFamilias, err := familias.ListarFamilias(ctx)
+	if err != nil {
+		return planImportacion{}, err
+	}
+
+	plan, err := planificarImportacion(filas, conFamilias, actuales, listaFamilias)
+	if err != nil {
+		return plan, &errorApi{Tipo: tipoNoProcesable, Mensaje: err.Error()}
+	}
+	return plan, nil
+}
+
+// importarInvitados recibe el archivo en el campo "archivo" de un formulario
+// multipart. Con ?dry_run=true solo devuelve el plan; con ?eliminar=true borra
+// los invitados que no aparecen en el archivo.
+func (s *servidor) importarInvitados(gc *gin.Context) {
+	dryRun := gc.Query("dry_run") == "true"
+	eliminar := gc.Query("eliminar") == "true"
+
+	encabezado, err := gc.FormFile("archivo")
+	if err != nil {
+		s.responderError(gc, entradaInvalida("Falta el archivo con la lista de invitados"))
+		return
+	}
+
+	archivo, err := encabezado.Open()
+	if err != nil {
+		s.responderError(gc, entradaInvalida("No se pudo leer el archivo"))
+		return
+	}
+	defer archivo.Close()
+
+	filas, conFamilias, err := leerArchivoInvitados(encabezado.Filename, archivo)
+	if err != nil {
+		s.responderError(gc, entradaInvalida(fmt.Sprintf("El archivo es incorrecto: %s", err)))
+		return
+	}
+
+	plan, err := prepararImportacion(gc.Request.Context(), s.invitados, s.familias, filas, conFamilias)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	if dryRun {
+		s.json(gc, http.StatusOK, gin.H{"aplicado": false, "plan": plan})
+		return
+	}
+
+	if err := s.invitados.AplicarImportacion(gc.Request.Context(), plan, eliminar); err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusOK, gin.H{"aplicado": true, "plan": plan})
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Tipos de destino de un token de invitación.
+const (
+	invitacionInvitado = "invitado"
+	invitacionFamilia  = "familia"
+)
+
+// Invitacion es un token de enlace vigente junto al invitado o la familia a
+// la que pertenece. Token puede ser de la base o un enlace firmado.
+type Invitacion struct {
+	Token     string     `json:"token"`
+	Tipo      string     `json:"tipo"`
+	Id_text   string     `json:"id_text"`
+	Nombre    string     `json:"nombre"`
+	Expira_en *time.Time `json:"expira_en,omitempty"`
+}
+
+type InvitacionCommand struct {
+	Expira_en *time.Time `json:"expira_en"`
+}
+
+// generarTokenInvitacion devuelve 128 bits aleatorios en hexadecimal, que se
+// pueden usar tal cual en URLs y en ids de HTML.
+func generarTokenInvitacion() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("generarTokenInvitacion %s", err)
+	}
+	return hex.EncodeToString(bytes), nil
+}
+
+// resolverInvitacion traduce el token de un enlace a su invitado o familia.
+// Solo acepta los tipos indicados; con IDTEXTLEGADO también acepta un id_text.
+// Los enlaces firmados se verifican sin consultar la base y deben incluir el
+// alcance pedido, si no está vacío. La invitación queda como autor de los
+// cambios que haga la petición.
+func (s *servidor) resolverInvitacion(gc *gin.Context, token string, alcance string, tipos ...string) (Invitacion, error) {
+	ctx := gc.Request.Context()
+
+	var invitacion Invitacion
+	var err error
+	if esEnlaceFirmado(token) {
+		invitacion, err = s.enlaceFirmado(gc, token, alcance)
+	} else {
+		invitacion, err = s.invitaciones.ResolverInvitacion(ctx, token)
+		if errors.Is(err, errInvitacionInvalida) && s.aceptarIdText {
+			invitacion, err = s.invitacionPorIdText(ctx, token, tipos)
+		}
+	}
+	if err != nil {
+		return Invitacion{}, err
+	}
+
+	for _, tipo := range tipos {
+		if invitacion.Tipo == tipo {
+			autorDeContexto(ctx).agregarInvitacion(invitacion)
+			return invitacion, nil
+		}
+	}
+	return Invitacion{}, errInvitacionInvalida
+}
+
+// enlaceFirmado reutiliza lo que ya verificó requiereAlcance en esta petición.
+func (s *servidor) enlaceFirmado(gc *gin.Context, enlace string, alcance string) (Invitacion, error) {
+	if valor, ok := gc.Get(claveEnlaceFirmadoCtx); ok {
+		if invitacion, ok := valor.(Invitacion); ok && invitacion.Token == enlace {
+			return invitacion, nil
+		}
+	}
+
+	invitacion, err := s.firmas.verificar(enlace, alcance)
+	if err != nil {
+		return Invitacion{}, errInvitacionInvalida
+	}
+	return invitacion, nil
+}
+
+// tokenMiembro devuelve el token con el que responde un miembro de la familia.
+// Si la familia entró con un enlace firmado, el miembro recibe otro firmado
+// con la misma expiración, solo si el de la familia permite responder; si no,
+// su token de la base. Vacío si lo revocaron o si la gestión pidió la familia
+// por id_text, sin token.
+func (s *servidor) tokenMiembro(ctx context.Context, familia Invitacion, invitado InvitadoResp) (string, error) {
+	// Los acompañantes responden junto con quien los nombró.
+	if familia.Token == "" || invitado.Anfitrion != nil {
+		return "", nil
+	}
+	if esEnlaceFirmado(familia.Token) {
+		if _, err := s.firmas.verificar(familia.Token, alcanceRsvp); err != nil {
+			return "", nil
+		}
+		var expira time.Time
+		if familia.Expira_en != nil {
+			expira = *familia.Expira_en
+		}
+		return s.firmas.firmar(invitacionInvitado, invitado.Id_text, []string{alcanceRsvp}, expira)
+	}
+
+	token, err := s.invitaciones.TokenInvitacion(ctx, invitacionInvitado, invitado.Id_text)
+	if errors.Is(err, errInvitacionInvalida) {
+		return "", nil
+	}
+	return token, err
+}
+
+func (s *servidor) invitacionPorIdText(ctx context.Context, idText string, tipos []string) (Invitacion, error) {
+	for _, tipo := range tipos {
+		switch tipo {
+		case invitacionInvitado:
+			invitado, err := s.invitados.InvitadoPorId(ctx, idText)
+			if err == nil {
+				return Invitacion{Token: idText, Tipo: tipo, Id_text: idText, Nombre: invitado.Nombre}, nil
+			}
+			if !errors.Is(err, errInvitadoNoEncontrado) {
+				return Invitacion{}, err
+			}
+		case invitacionFamilia:
+			familia, err := s.familias.FamiliaPorId(ctx, idText)
+			if err == nil {
+				return Invitacion{Token: idText, Tipo: tipo, Id_text: idText, Nombre: familia.Nombre}, nil
+			}
+			if !errors.Is(err, errFamiliaNoEncontrada) {
+				return Invitacion{}, err
+			}
+		}
+	}
+	return Invitacion{}, errInvitacionInvalida
+}
+
+// errorInvitacion traduce los errores al consultar o rotar la invitación del
+// invitado o la familia id.
+func errorInvitacion(id string, err error) error {
+	switch {
+	case errors.Is(err, errInvitadoNoEncontrado):
+		return errorInvitado(id, err)
+	case errors.Is(err, errFamiliaNoEncontrada):
+		return errorFamilia(id, err)
+	case errors.Is(err, errInvitacionInvalida):
+		return &errorApi{Tipo: tipoExpirado, Mensaje: "La invitación fue revocada o expiró, rótala para emitir una nueva"}
+	case errors.Is(err, errInvitadoEsAcompanante):
+		return conflicto("Los acompañantes responden con el enlace de quien los nombró")
+	default:
+		return err
+	}
+}
+
+func (s *servidor) getInvitaciones(gc *gin.Context) {
+	invitaciones, err := s.invitaciones.ListarInvitaciones(gc.Request.Context())
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitaciones)
+}
+
+// getInvitacion devuelve el token vigente del invitado o la familia :id y lo
+// emite si todavía no tenía uno.
+func (s *servidor) getInvitacion(tipo string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		id := gc.Param("id")
+		ctx := gc.Request.Context()
+
+		token, err := s.invitaciones.TokenInvitacion(ctx, tipo, id)
+		if err != nil {
+			s.responderError(gc, errorInvitacion(id, err))
+			return
+		}
+
+		invitacion, err := s.invitaciones.ResolverInvitacion(ctx, token)
+		if err != nil {
+			s.responderError(gc, errorInvitacion(id, err))
+			return
+		}
+
+		s.json(gc, http.StatusOK, invitacion)
+	}
+}
+
+// rotarInvitacion revoca el token vigente y emite uno nuevo. El cuerpo es
+// opcional y puede traer la fecha de expiración en RFC 3339.
+func (s *servidor) rotarInvitacion(tipo string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		id := gc.Param("id")
+		var cmd InvitacionCommand
+
+		if gc.Request.ContentLength != 0 {
+			if err := gc.ShouldBindJSON(&cmd); err != nil {
+				s.responderError(gc, peticionIncorrecta(err))
+				return
+			}
+		}
+
+		var expira time.Time
+		if cmd.Expira_en != nil {
+			if !cmd.Expira_en.After(time.Now()) {
+				s.responderError(gc, entradaInvalida("La fecha de expiración debe ser futura"))
+				return
+			}
+			expira = *cmd.Expira_en
+		}
+
+		invitacion, err := s.invitaciones.RotarInvitacion(gc.Request.Context(), tipo, id, expira)
+		if err != nil {
+			s.responderError(gc, errorInvitacion(id, err))
+			return
+		}
+
+		s.json(gc, http.StatusCreated, invitacion)
+	}
+}
+
+func (s *servidor) revocarInvitacion(tipo string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		id := gc.Param("id")
+
+		if err := s.invitaciones.RevocarInvitacion(gc.Request.Context(), tipo, id); err != nil {
+			s.responderError(gc, errorInvitacion(id, err))
+			return
+		}
+
+		gc.Status(http.StatusNoContent)
+	}
+}
+
+// errorEnlace traduce un error de resolverInvitacion, o de buscar al invitado
+// o la familia del enlace, para quien lo abrió. No distingue entre un token que
+// nunca existió, uno revocado o uno cuyo destino se eliminó.
+func errorEnlace(err error) error {
+	if errors.Is(err, errInvitacionInvalida) || errors.Is(err, errInvitadoNoEncontrado) || errors.Is(err, errFamiliaNoEncontrada) {
+		return noEncontrado("El enlace no es válido o expiró")
+	}
+	return err
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// servidorPrueba arma un servidor sobre store con solo las rutas de
+// gestión de invitaciones, sin autenticación.
+func servidorPrueba(store *sqlStore) (*servidor, *gin.Engine) {
+	gin.SetMode(gin.TestMode)
+
+	s := nuevoServidor(store, store, store, store, store, store, store, store, store)
+	router := gin.New()
+	router.GET("/invitados/:id/invitacion", s.getInvitacion(invitacionInvitado))
+	router.POST("/invitados/:id/invitacion", s.rotarInvitacion(invitacionInvitado))
+	return s, router
+}
+
+func TestInvitacionAcompanante(t *testing.T) {
+	store := storePrueba(t)
+	anfitrion, acompanante := acompanantePrueba(t, store)
+	_, router := servidorPrueba(store)
+
+	casos := []struct {
+		nombre string
+		metodo string
+		idText string
+		estado int
+	}{
+		{"token del anfitrión", http.MethodGet, anfitrion, http.StatusOK},
+		{"rotar el del anfitrión", http.MethodPost, anfitrion, http.StatusCreated},
+		{"token del acompañante", http.MethodGet, acompanante, http.StatusConflict},
+		{"rotar el del acompañante", http.MethodPost, acompanante, http.StatusConflict},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			respuesta := httptest.NewRecorder()
+			router.ServeHTTP(respuesta, httptest.NewRequest(c.metodo, "/invitados/"+c.idText+"/invitacion", nil))
+			if respuesta.Code != c.estado {
+				t.Errorf("%s = %v, se esperaba %v: %s", c.metodo, respuesta.Code, c.estado, respuesta.Body)
+			}
+		})
+	}
+
+	invitaciones, err := store.ListarInvitaciones(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range invitaciones {
+		if i.Id_text == acompanante {
+			t.Errorf("se emitió un token para el acompañante: %+v", i)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const caracteresIdText = "abcdefghijkmnpqrstuvwxyz23456789"
+const largoIdText = 10
+const largoMaximoNombre = 100
+
+// normalizar quita espacios sobrantes de los campos de texto del comando.
+func (cmd *InvitadoCommand) normalizar() {
+	cmd.Nombre = strings.TrimSpace(cmd.Nombre)
+	cmd.Nombre_invitacion = strings.TrimSpace(cmd.Nombre_invitacion)
+}
+
+func (cmd InvitadoCommand) validar() error {
+	if cmd.Nombre == "" {
+		return errors.New("el nombre es obligatorio")
+	}
+	if cmd.Nombre_invitacion == "" {
+		return errors.New("el nombre de la invitación es obligatorio")
+	}
+	if utf8.RuneCountInString(cmd.Nombre) > largoMaximoNombre {
+		return fmt.Errorf("el nombre no puede superar %v caracteres", largoMaximoNombre)
+	}
+	if utf8.RuneCountInString(cmd.Nombre_invitacion) > largoMaximoNombre {
+		return fmt.Errorf("el nombre de la invitación no puede superar %v caracteres", largoMaximoNombre)
+	}
+	return nil
+}
+
+// generarIdText crea un identificador aleatorio sin caracteres ambiguos para
+// usar en los enlaces de las invitaciones.
+func generarIdText() (string, error) {
+	var sb strings.Builder
+	max := big.NewInt(int64(len(caracteresIdText)))
+	for i := 0; i < largoIdText; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", fmt.Errorf("generarIdText %s", err)
+		}
+		sb.WriteByte(caracteresIdText[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+func (s *servidor) crearInvitado(gc *gin.Context) {
+	var cmd InvitadoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	invitado, err := s.invitados.CrearInvitado(gc.Request.Context(), cmd)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusCreated, invitado)
+}
+
+// reemplazarInvitado sobrescribe todos los campos del invitado. Un Asiste
+// ausente deja al invitado sin respuesta.
+func (s *servidor) reemplazarInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd InvitadoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	s.guardarInvitado(gc, id, cmd, s.invitados.ReemplazarInvitado)
+}
+
+// modificarInvitado solo cambia los campos enviados: los textos vacíos y un
+// Asiste ausente conservan el valor actual. La asistencia no se copia al
+// comando, porque con eventos es solo un resumen de sus respuestas.
+func (s *servidor) modificarInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd InvitadoCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	actual, err := s.invitados.InvitadoPorId(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	cmd.normalizar()
+	if cmd.Nombre == "" {
+		cmd.Nombre = actual.Nombre
+	}
+	if cmd.Nombre_invitacion == "" {
+		cmd.Nombre_invitacion = actual.Nombre_invitacion
+	}
+
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	s.guardarInvitado(gc, id, cmd, s.invitados.ModificarInvitado)
+}
+
+func (s *servidor) guardarInvitado(gc *gin.Context, id string, cmd InvitadoCommand, guardar func(context.Context, string, InvitadoCommand) (InvitadoResp, error)) {
+	invitado, err := guardar(gc.Request.Context(), id, cmd)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitado)
+}
+
+func (s *servidor) eliminarInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+
+	err := s.invitados.EliminarInvitado(gc.Request.Context(), id)
+	if errors.Is(err, errInvitadoEsPrincipal) {
+		s.responderError(gc, conflicto(fmt.Sprintf("No se puede eliminar el invitado %v porque es el miembro principal de una familia", id)))
+		return
+	}
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}
+
+// errorInvitado traduce errInvitadoNoEncontrado a un 404 con el id pedido; los
+// demás errores quedan como internos.
+func errorInvitado(id string, err error) error {
+	if errors.Is(err, errInvitadoNoEncontrado) {
+		return noEncontrado(fmt.Sprintf("No se encontró un invitado con el id %v", id))
+	}
+	return err
+}
@@ -0,0 +1,296 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limite permite Cantidad peticiones por Periodo, con ráfagas de hasta
+// Cantidad. Una Cantidad cero desactiva el límite.
+type limite struct {
+	Cantidad int
+	Periodo  time.Duration
+}
+
+func (l limite) activo() bool {
+	return l.Cantidad > 0 && l.Periodo > 0
+}
+
+// parsearLimite lee valores como "30/1m"; "off" desactiva el límite.
+func parsearLimite(valor string) (limite, error) {
+	if valor == "off" {
+		return limite{}, nil
+	}
+
+	cantidad, periodo, ok := strings.Cut(valor, "/")
+	if !ok {
+		return limite{}, fmt.Errorf("límite inválido %q, usa cantidad/duración como 30/1m", valor)
+	}
+	n, err := strconv.Atoi(cantidad)
+	if err != nil || n < 0 {
+		return limite{}, fmt.Errorf("límite inválido %q, usa cantidad/duración como 30/1m", valor)
+	}
+	d, err := time.ParseDuration(periodo)
+	if err != nil || d <= 0 {
+		return limite{}, fmt.Errorf("límite inválido %q, usa cantidad/duración como 30/1m", valor)
+	}
+
+	return limite{Cantidad: n, Periodo: d}, nil
+}
+
+// limitesPeticiones agrupa los límites de las rutas públicas.
+type limitesPeticiones struct {
+	// PorIP aplica a todas las rutas de invitados.
+	PorIP limite
+	// PorToken aplica a cada enlace al abrirlo y al responder.
+	PorToken limite
+	// Escritura es el límite por enlace de canciones y mensajes.
+	Escritura limite
+	// Bloqueo bloquea una IP durante Periodo tras Cantidad búsquedas de
+	// enlaces inexistentes dentro de ese mismo periodo.
+	Bloqueo limite
+}
+
+func cargarLimites() (limitesPeticiones, error) {
+	var limites limitesPeticiones
+	for _, v := range []struct {
+		variable, porDefecto string
+		destino              *limite
+	}{
+		{"LIMITEIP", "60/1m", &limites.PorIP},
+		{"LIMITETOKEN", "20/1m", &limites.PorToken},
+		{"LIMITEESCRITURA", "10/10m", &limites.Escritura},
+		{"BLOQUEOFALLOS", "10/15m", &limites.Bloqueo},
+	} {
+		valor := os.Getenv(v.variable)
+		if valor == "" {
+			valor = v.porDefecto
+		}
+		l, err := parsearLimite(valor)
+		if err != nil {
+			return limites, fmt.Errorf("%s: %s", v.variable, err)
+		}
+		*v.destino = l
+	}
+	return limites, nil
+}
+
+// RateLimitStore guarda los baldes del limitador y los fallos de búsqueda.
+// La implementación en memoria sirve para una sola instancia; con varias se
+// puede cambiar por una compartida.
+type RateLimitStore interface {
+	// Tomar descuenta una petición del balde clave. Si está vacío devuelve
+	// falso y cuánto falta para la siguiente.
+	Tomar(ctx context.Context, clave string, l limite, ahora time.Time) (bool, time.Duration, error)
+	// RegistrarFallo cuenta una búsqueda fallida de clave y devuelve hasta
+	// cuándo queda bloqueada, o cero si todavía no lo está.
+	RegistrarFallo(ctx context.Context, clave string, l limite, ahora time.Time) (time.Time, error)
+	// BloqueadoHasta devuelve cero si la clave no está bloqueada.
+	BloqueadoHasta(ctx context.Context, clave string, ahora time.Time) (time.Time, error)
+}
+
+type balde struct {
+	tokens float64
+	ultimo time.Time
+	// lleno es cuando el balde vuelve a estar completo y se puede olvidar.
+	lleno time.Time
+}
+
+type registroFallos struct {
+	cuenta         int
+	desde          time.Time
+	periodo        time.Duration
+	bloqueadoHasta time.Time
+}
+
+// limitadorMemoria implementa RateLimitStore con mapas protegidos por un mutex.
+type limitadorMemoria struct {
+	mu             sync.Mutex
+	baldes         map[string]*balde
+	fallos         map[string]*registroFallos
+	ultimaLimpieza time.Time
+}
+
+func nuevoLimitadorMemoria() *limitadorMemoria {
+	return &limitadorMemoria{
+		baldes: map[string]*balde{},
+		fallos: map[string]*registroFallos{},
+	}
+}
+
+func (m *limitadorMemoria) Tomar(ctx context.Context, clave string, l limite, ahora time.Time) (bool, time.Duration, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limpiar(ahora)
+
+	porSegundo := float64(l.Cantidad) / l.Periodo.Seconds()
+
+	b, ok := m.baldes[clave]
+	if !ok {
+		b = &balde{tokens: float64(l.Cantidad), ultimo: ahora}
+		m.baldes[clave] = b
+	}
+	b.tokens = math.Min(float64(l.Cantidad), b.tokens+ahora.Sub(b.ultimo).Seconds()*porSegundo)
+	b.ultimo = ahora
+
+	if b.tokens < 1 {
+		espera := time.Duration((1 - b.tokens) / porSegundo * float64(time.Second))
+		return false, espera, nil
+	}
+
+	b.tokens--
+	b.lleno = ahora.Add(time.Duration((float64(l.Cantidad) - b.tokens) / porSegundo * float64(time.Second)))
+	return true, 0, nil
+}
+
+func (m *limitadorMemoria) RegistrarFallo(ctx context.Context, clave string, l limite, ahora time.Time) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, ok := m.fallos[clave]
+	if !ok || ahora.Sub(r.desde) > l.Periodo {
+		r = &registroFallos{desde: ahora, periodo: l.Periodo}
+		m.fallos[clave] = r
+	}
+
+	r.cuenta++
+	if r.cuenta >= l.Cantidad {
+		r.bloqueadoHasta = ahora.Add(l.Periodo)
+	}
+	return r.bloqueadoHasta, nil
+}
+
+func (m *limitadorMemoria) BloqueadoHasta(ctx context.Context, clave string, ahora time.Time) (time.Time, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if r, ok := m.fallos[clave]; ok && ahora.Before(r.bloqueadoHasta) {
+		return r.bloqueadoHasta, nil
+	}
+	return time.Time{}, nil
+}
+
+// limpiar olvida, como mucho una vez por minuto, los baldes llenos y los
+// fallos vencidos para que la memoria no crezca con cada IP. Se llama con el
+// mutex tomado.
+func (m *limitadorMemoria) limpiar(ahora time.Time) {
+	if ahora.Sub(m.ultimaLimpieza) < time.Minute {
+		return
+	}
+	m.ultimaLimpieza = ahora
+
+	for clave, b := range m.baldes {
+		if ahora.After(b.lleno) {
+			delete(m.baldes, clave)
+		}
+	}
+	for clave, r := range m.fallos {
+		if ahora.After(r.bloqueadoHasta) && ahora.Sub(r.desde) > r.periodo {
+			delete(m.fallos, clave)
+		}
+	}
+}
+
+// responderLimite corta la petición con 429. A htmx le llega un fragmento que
+// puede mostrar en lugar de los botones; al resto, JSON.
+func (s *servidor) responderLimite(gc *gin.Context, espera time.Duration) {
+	segundos := int(math.Ceil(espera.Seconds()))
+	if segundos < 1 {
+		segundos = 1
+	}
+
+	gc.Header("Retry-After", strconv.Itoa(segundos))
+
+	if gc.GetHeader("HX-Request") == "true" {
+		s.html(gc, http.StatusTooManyRequests, "limite-excedido", segundos)
+		gc.Abort()
+		return
+	}
+
+	s.responderError(gc, &errorApi{Tipo: tipoLimiteExcedido, Mensaje: fmt.Sprintf("Demasiados intentos, espera %v segundos", segundos)})
+}
+
+// claveFallosCtx guarda cuántas búsquedas fallidas hizo la petición cuando
+// son más de una.
+const claveFallosCtx = "fallosBusqueda"
+
+// contarFallos marca que la petición buscó n enlaces inexistentes, como el
+// lote con varios tokens inválidos.
+func contarFallos(gc *gin.Context, n int) {
+	gc.Set(claveFallosCtx, n)
+}
+
+// limitar aplica el límite por IP y, si porToken está activo, el del enlace
+// de la petición. Las búsquedas que terminan en 404 cuentan como un fallo, o
+// como los que indicó contarFallos, y al superar el límite de bloqueo la IP
+// queda bloqueada en todas las rutas.
+// Si el store falla la petición pasa, para no dejar fuera a los invitados.
+func (s *servidor) limitar(grupo string, porToken limite) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		ctx := gc.Request.Context()
+		ahora := time.Now()
+		ip := gc.ClientIP()
+
+		if s.limites.Bloqueo.activo() {
+			hasta, err := s.limitador.BloqueadoHasta(ctx, "bloqueo:"+ip, ahora)
+			if err != nil {
+				log.Printf("limitar %s", err)
+			} else if !hasta.IsZero() {
+				s.responderLimite(gc, hasta.Sub(ahora))
+				return
+			}
+		}
+
+		type baldePeticion struct {
+			clave string
+			l     limite
+		}
+		var baldes []baldePeticion
+		if s.limites.PorIP.activo() {
+			baldes = append(baldes, baldePeticion{grupo + ":ip:" + ip, s.limites.PorIP})
+		}
+		if porToken.activo() {
+			if credencial := credencialDePeticion(gc); credencial != "" {
+				baldes = append(baldes, baldePeticion{grupo + ":token:" + credencial, porToken})
+			}
+		}
+
+		for _, b := range baldes {
+			permitido, espera, err := s.limitador.Tomar(ctx, b.clave, b.l, ahora)
+			if err != nil {
+				log.Printf("limitar %s", err)
+				continue
+			}
+			if !permitido {
+				s.responderLimite(gc, espera)
+				return
+			}
+		}
+
+		gc.Next()
+
+		if !s.limites.Bloqueo.activo() {
+			return
+		}
+		fallos := gc.GetInt(claveFallosCtx)
+		if fallos == 0 && gc.Writer.Status() == http.StatusNotFound {
+			fallos = 1
+		}
+		for i := 0; i < fallos; i++ {
+			if _, err := s.limitador.RegistrarFallo(ctx, "bloqueo:"+ip, s.limites.Bloqueo, time.Now()); err != nil {
+				log.Printf("limitar %s", err)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,540 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	largoMaximoDescripcion = 500
+	largoMaximoRestriccion = 40
+	maximoRestricciones    = 10
+	largoMaximoAlergias    = 500
+	largoMaximoMesa        = 64
+)
+
+// etiquetasDieta son las restricciones que el formulario ofrece siempre. Un
+// invitado puede mandar otras por la API y se guardan igual.
+var etiquetasDieta = []string{"vegetariano", "vegano", "sin gluten", "sin lactosa"}
+
+// PlatilloResp es una de las opciones del menú. Orden decide en qué orden se
+// muestran a los invitados.
+type PlatilloResp struct {
+	Id          int64  `json:"id"`
+	Id_text     string `json:"id_text"`
+	Nombre      string `json:"nombre"`
+	Descripcion string `json:"descripcion"`
+	Orden       int    `json:"orden"`
+}
+
+type PlatilloCommand struct {
+	Nombre      string `json:"nombre"`
+	Descripcion string `json:"descripcion"`
+	Orden       int    `json:"orden"`
+}
+
+// EleccionMenu es lo que eligió un invitado. Platillo es el id_text del
+// platillo, vacío mientras no elige.
+type EleccionMenu struct {
+	Platillo      string   `json:"platillo"`
+	Restricciones []string `json:"restricciones"`
+	Alergias      string   `json:"alergias"`
+}
+
+// MenuCommand reemplaza la elección completa: las restricciones que no vengan
+// se borran.
+type MenuCommand struct {
+	Invitado_Id   string       `json:"invitado_id"`
+	Platillo      string       `json:"platillo"`
+	Restricciones listaNombres `json:"restricciones"`
+	Alergias      string       `json:"alergias"`
+}
+
+type MesaCommand struct {
+	Mesa string `json:"mesa"`
+}
+
+// menuConfirmado es la elección de un invitado que aceptó, para el reporte.
+type menuConfirmado struct {
+	Nombre        string   `json:"nombre"`
+	Mesa          string   `json:"-"`
+	Id_platillo   string   `json:"-"`
+	Platillo      string   `json:"platillo"`
+	Restricciones []string `json:"restricciones"`
+	Alergias      string   `json:"alergias"`
+}
+
+type conteoPlatillo struct {
+	Id_text  string `json:"id_text"`
+	Nombre   string `json:"nombre"`
+	Cantidad int    `json:"cantidad"`
+}
+
+type conteoRestriccion struct {
+	Restriccion string `json:"restriccion"`
+	Cantidad    int    `json:"cantidad"`
+}
+
+// mesaCatering son los invitados de una mesa con restricciones o alergias.
+// Mesa vacía junta a los que todavía no tienen mesa.
+type mesaCatering struct {
+	Mesa      string           `json:"mesa"`
+	Invitados []menuConfirmado `json:"invitados"`
+}
+
+// ReporteCatering resume el menú de los invitados que aceptaron, acompañantes
+// incluidos.
+type ReporteCatering struct {
+	Invitados     int                 `json:"invitados"`
+	Platillos     []conteoPlatillo    `json:"platillos"`
+	Sin_elegir    int                 `json:"sin_elegir"`
+	Restricciones []conteoRestriccion `json:"restricciones"`
+	Mesas         []mesaCatering      `json:"mesas"`
+}
+
+func (cmd *PlatilloCommand) normalizar() {
+	cmd.Nombre = strings.TrimSpace(cmd.Nombre)
+	cmd.Descripcion = strings.TrimSpace(cmd.Descripcion)
+}
+
+func (cmd PlatilloCommand) validar() error {
+	if cmd.Nombre == "" {
+		return errors.New("el nombre es obligatorio")
+	}
+	if utf8.RuneCountInString(cmd.Nombre) > largoMaximoNombre {
+		return fmt.Errorf("el nombre no puede superar %v caracteres", largoMaximoNombre)
+	}
+	if utf8.RuneCountInString(cmd.Descripcion) > largoMaximoDescripcion {
+		return fmt.Errorf("la descripción no puede superar %v caracteres", largoMaximoDescripcion)
+	}
+	return nil
+}
+
+// normalizar pasa las restricciones a minúsculas y quita las vacías y las
+// repetidas.
+func (cmd *MenuCommand) normalizar() {
+	cmd.Platillo = strings.TrimSpace(cmd.Platillo)
+	cmd.Alergias = strings.TrimSpace(cmd.Alergias)
+
+	restricciones := listaNombres{}
+	vistas := map[string]bool{}
+	for _, restriccion := range cmd.Restricciones {
+		restriccion = strings.ToLower(strings.Join(strings.Fields(restriccion), " "))
+		if restriccion == "" || vistas[restriccion] {
+			continue
+		}
+		vistas[restriccion] = true
+		restricciones = append(restricciones, restriccion)
+	}
+	cmd.Restricciones = restricciones
+}
+
+func (cmd MenuCommand) validar() error {
+	if len(cmd.Restricciones) > maximoRestricciones {
+		return fmt.Errorf("indica como mucho %v restricciones", maximoRestricciones)
+	}
+	for _, restriccion := range cmd.Restricciones {
+		if strings.Contains(restriccion, ",") {
+			return errors.New("una restricción no puede llevar comas")
+		}
+		if utf8.RuneCountInString(restriccion) > largoMaximoRestriccion {
+			return fmt.Errorf("una restricción no puede superar %v caracteres", largoMaximoRestriccion)
+		}
+	}
+	if utf8.RuneCountInString(cmd.Alergias) > largoMaximoAlergias {
+		return fmt.Errorf("las alergias no pueden superar %v caracteres", largoMaximoAlergias)
+	}
+	return nil
+}
+
+func (cmd MenuCommand) eleccion() EleccionMenu {
+	return EleccionMenu{Platillo: cmd.Platillo, Restricciones: cmd.Restricciones, Alergias: cmd.Alergias}
+}
+
+func (cmd *MesaCommand) normalizar() {
+	cmd.Mesa = strings.TrimSpace(cmd.Mesa)
+}
+
+func (cmd MesaCommand) validar() error {
+	if utf8.RuneCountInString(cmd.Mesa) > largoMaximoMesa {
+		return fmt.Errorf("la mesa no puede superar %v caracteres", largoMaximoMesa)
+	}
+	return nil
+}
+
+// menuVista son los datos de la plantilla menu. Mientras el invitado no
+// acepta la plantilla deja solo el contenedor vacío, que se llena al aceptar.
+// Oob la marca para que htmx la cambie junto a los botones de respuesta.
+type menuVista struct {
+	Token     string
+	Asiste    bool
+	Platillos []PlatilloResp
+	Eleccion  EleccionMenu
+	Aviso     string
+	Oob       bool
+}
+
+type etiquetaVista struct {
+	Nombre  string
+	Marcada bool
+}
+
+// Etiquetas son las de etiquetasDieta seguidas de las demás que ya eligió el
+// invitado.
+func (v menuVista) Etiquetas() []etiquetaVista {
+	elegidas := map[string]bool{}
+	for _, restriccion := range v.Eleccion.Restricciones {
+		elegidas[restriccion] = true
+	}
+
+	etiquetas := make([]etiquetaVista, 0, len(etiquetasDieta))
+	for _, etiqueta := range etiquetasDieta {
+		etiquetas = append(etiquetas, etiquetaVista{Nombre: etiqueta, Marcada: elegidas[etiqueta]})
+		delete(elegidas, etiqueta)
+	}
+	for _, restriccion := range v.Eleccion.Restricciones {
+		if elegidas[restriccion] {
+			etiquetas = append(etiquetas, etiquetaVista{Nombre: restriccion, Marcada: true})
+		}
+	}
+	return etiquetas
+}
+
+// vistaMenu arma el formulario de menú del invitado. Devuelve nil si la boda
+// no tiene platillos o si el invitado es acompañante, porque no responde por
+// su cuenta.
+func (s *servidor) vistaMenu(ctx context.Context, invitado InvitadoResp, token string) (*menuVista, error) {
+	if token == "" || invitado.Anfitrion != nil {
+		return nil, nil
+	}
+
+	platillos, err := s.menus.ListarPlatillos(ctx)
+	if err != nil || len(platillos) == 0 {
+		return nil, err
+	}
+
+	vista := &menuVista{Token: token, Asiste: invitado.Asiste.Valid && invitado.Asiste.Bool, Platillos: platillos}
+	if !vista.Asiste {
+		return vista, nil
+	}
+
+	vista.Eleccion, err = s.menus.MenuDeInvitado(ctx, invitado.Id_text)
+	if err != nil {
+		return nil, err
+	}
+	return vista, nil
+}
+
+// menuTrasResponder es el formulario de menú que acompaña a los botones tras
+// responder, para que aparezca al aceptar y desaparezca al rechazar.
+func (s *servidor) menuTrasResponder(ctx context.Context, invitacion Invitacion) (*menuVista, error) {
+	invitado, err := s.invitados.InvitadoPorId(ctx, invitacion.Id_text)
+	if err != nil {
+		return nil, err
+	}
+
+	vista, err := s.vistaMenu(ctx, invitado, invitacion.Token)
+	if vista != nil {
+		vista.Oob = true
+	}
+	return vista, err
+}
+
+func (s *servidor) getPlatillos(gc *gin.Context) {
+	platillos, err := s.menus.ListarPlatillos(gc.Request.Context())
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusOK, platillos)
+}
+
+func (s *servidor) getPlatillo(gc *gin.Context) {
+	id := gc.Param("id")
+
+	platillo, err := s.menus.PlatilloPorId(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorMenu(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, platillo)
+}
+
+func (s *servidor) crearPlatillo(gc *gin.Context) {
+	var cmd PlatilloCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	platillo, err := s.menus.CrearPlatillo(gc.Request.Context(), cmd)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusCreated, platillo)
+}
+
+func (s *servidor) reemplazarPlatillo(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd PlatilloCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	platillo, err := s.menus.ReemplazarPlatillo(gc.Request.Context(), id, cmd)
+	if err != nil {
+		s.responderError(gc, errorMenu(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, platillo)
+}
+
+func (s *servidor) eliminarPlatillo(gc *gin.Context) {
+	id := gc.Param("id")
+
+	if err := s.menus.EliminarPlatillo(gc.Request.Context(), id); err != nil {
+		s.responderError(gc, errorMenu(id, err))
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}
+
+// elegirMenu guarda el menú del invitado del token, hasta el cierre del plazo.
+// En HTML devuelve el formulario con un aviso, también cuando algo falla.
+func (s *servidor) elegirMenu(gc *gin.Context) {
+	var cmd MenuCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	ctx := gc.Request.Context()
+
+	invitacion, err := s.resolverInvitacion(gc, cmd.Invitado_Id, alcanceRsvp, invitacionInvitado)
+	if err != nil {
+		s.responderError(gc, errorEnlace(err))
+		return
+	}
+
+	cmd.normalizar()
+	vista := menuVista{Token: invitacion.Token, Asiste: true, Eleccion: cmd.eleccion()}
+	if vista.Platillos, err = s.menus.ListarPlatillos(ctx); err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	plazo, err := s.plazoDeInvitado(ctx, invitacion.Id_text, nil)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	if plazo.Cerrado {
+		vencido := plazoVencido(plazo)
+		vista.Aviso = vencido.Mensaje
+		s.responderFormulario(gc, vencido, "menu", vista)
+		return
+	}
+
+	if err := cmd.validar(); err != nil {
+		vista.Aviso = err.Error()
+		s.responderFormulario(gc, entradaInvalida(err.Error()), "menu", vista)
+		return
+	}
+
+	eleccion, err := s.menus.ElegirMenu(ctx, invitacion.Id_text, cmd.eleccion())
+	if err != nil {
+		err = errorMenu(cmd.Platillo, err)
+		vista.Aviso = "Error, intentalo de nuevo"
+		var e *errorApi
+		if errors.As(err, &e) {
+			vista.Aviso = e.Mensaje
+		}
+		s.responderFormulario(gc, err, "menu", vista)
+		return
+	}
+
+	vista.Eleccion = eleccion
+	vista.Aviso = "¡Gracias! Guardamos tu menú"
+	s.responder(gc, http.StatusOK, eleccion, "menu", vista)
+}
+
+// cambiarMenuInvitado deja a la gestión cargar el menú de cualquier invitado
+// que aceptó, por ejemplo el de un acompañante.
+func (s *servidor) cambiarMenuInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd MenuCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	eleccion, err := s.menus.ElegirMenu(gc.Request.Context(), id, cmd.eleccion())
+	if err != nil {
+		s.responderError(gc, errorMenu(cmd.Platillo, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, eleccion)
+}
+
+func (s *servidor) getMenuInvitado(gc *gin.Context) {
+	id := gc.Param("id")
+
+	eleccion, err := s.menus.MenuDeInvitado(gc.Request.Context(), id)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, eleccion)
+}
+
+func (s *servidor) cambiarMesa(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd MesaCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		s.responderError(gc, entradaInvalida(err.Error()))
+		return
+	}
+
+	invitado, err := s.invitados.CambiarMesa(gc.Request.Context(), id, cmd.Mesa)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitado)
+}
+
+// getReporteCatering cuenta los platillos y las restricciones de los que
+// aceptaron y lista, mesa por mesa, a quienes tienen restricciones o alergias.
+// Quien solo aceptó eventos marcados sin_menu no cuenta, porque no come en la
+// boda; sin eventos, cuenta con haber aceptado.
+func (s *servidor) getReporteCatering(gc *gin.Context) {
+	ctx := gc.Request.Context()
+
+	platillos, err := s.menus.ListarPlatillos(ctx)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	menus, err := s.menus.MenusConfirmados(ctx)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	reporte := ReporteCatering{
+		Invitados:     len(menus),
+		Platillos:     make([]conteoPlatillo, len(platillos)),
+		Restricciones: []conteoRestriccion{},
+		Mesas:         []mesaCatering{},
+	}
+	posPlatillo := map[string]int{}
+	for i, platillo := range platillos {
+		reporte.Platillos[i] = conteoPlatillo{Id_text: platillo.Id_text, Nombre: platillo.Nombre}
+		posPlatillo[platillo.Id_text] = i
+	}
+	posRestriccion := map[string]int{}
+	posMesa := map[string]int{}
+
+	for _, menu := range menus {
+		if i, ok := posPlatillo[menu.Id_platillo]; ok {
+			reporte.Platillos[i].Cantidad += 1
+		} else {
+			reporte.Sin_elegir += 1
+		}
+
+		for _, restriccion := range menu.Restricciones {
+			i, ok := posRestriccion[restriccion]
+			if !ok {
+				i = len(reporte.Restricciones)
+				posRestriccion[restriccion] = i
+				reporte.Restricciones = append(reporte.Restricciones, conteoRestriccion{Restriccion: restriccion})
+			}
+			reporte.Restricciones[i].Cantidad += 1
+		}
+
+		if len(menu.Restricciones) == 0 && menu.Alergias == "" {
+			continue
+		}
+		i, ok := posMesa[menu.Mesa]
+		if !ok {
+			i = len(reporte.Mesas)
+			posMesa[menu.Mesa] = i
+			reporte.Mesas = append(reporte.Mesas, mesaCatering{Mesa: menu.Mesa})
+		}
+		reporte.Mesas[i].Invitados = append(reporte.Mesas[i].Invitados, menu)
+	}
+
+	sort.SliceStable(reporte.Restricciones, func(i, j int) bool {
+		return reporte.Restricciones[i].Cantidad > reporte.Restricciones[j].Cantidad
+	})
+	// Los que no tienen mesa van al final.
+	sort.SliceStable(reporte.Mesas, func(i, j int) bool {
+		a, b := reporte.Mesas[i].Mesa, reporte.Mesas[j].Mesa
+		if a == "" || b == "" {
+			return b == "" && a != ""
+		}
+		return a < b
+	})
+
+	s.json(gc, http.StatusOK, reporte)
+}
+
+// errorMenu traduce los errores del store de menús; id es el platillo.
+func errorMenu(id string, err error) error {
+	switch {
+	case errors.Is(err, errPlatilloNoEncontrado):
+		return noEncontrado(fmt.Sprintf("No se encontró un platillo con el id %v", id))
+	case errors.Is(err, errMenuSinAsistencia):
+		return conflicto("Confirma tu asistencia antes de elegir tu menú")
+	case errors.Is(err, errInvitadoNoEncontrado):
+		return noEncontrado("No se encontró el invitado")
+	default:
+		return err
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cada migración es un par <version>_<nombre>.up.sql / .down.sql dentro de la
+// carpeta del driver. Las versiones aplicadas se guardan en schema_migraciones.
+//
+//go:embed migraciones
+var archivosMigraciones embed.FS
+
+type migracion struct {
+	Version int
+	Nombre  string
+	Subir   string
+	Bajar   string
+}
+
+type estadoMigracion struct {
+	Version    int
+	Nombre     string
+	AplicadaEn sql.NullTime
+}
+
+func cargarMigraciones(driver string) ([]migracion, error) {
+	carpeta := path.Join("migraciones", driver)
+	archivos, err := fs.ReadDir(archivosMigraciones, carpeta)
+	if err != nil {
+		return nil, fmt.Errorf("cargarMigraciones no hay migraciones para %q", driver)
+	}
+
+	porVersion := map[int]*migracion{}
+	for _, archivo := range archivos {
+		nombreArchivo := archivo.Name()
+		direccion := ""
+		switch {
+		case strings.HasSuffix(nombreArchivo, ".up.sql"):
+			direccion = "up"
+		case strings.HasSuffix(nombreArchivo, ".down.sql"):
+			direccion = "down"
+		default:
+			continue
+		}
+
+		base := strings.TrimSuffix(nombreArchivo, "."+direccion+".sql")
+		versionStr, nombre, _ := strings.Cut(base, "_")
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("cargarMigraciones versión inválida en %s", nombreArchivo)
+		}
+
+		contenido, err := fs.ReadFile(archivosMigraciones, path.Join(carpeta, nombreArchivo))
+		if err != nil {
+			return nil, fmt.Errorf("cargarMigraciones %s", err)
+		}
+
+		m, ok := porVersion[version]
+		if !ok {
+			m = &migracion{Version: version, Nombre: nombre}
+			porVersion[version] = m
+		}
+		if direccion == "up" {
+			m.Subir = string(contenido)
+		} else {
+			m.Bajar = string(contenido)
+		}
+	}
+
+	migraciones := make([]migracion, 0, len(porVersion))
+	for _, m := range porVersion {
+		if m.Subir == "" {
+			return nil, fmt.Errorf("cargarMigraciones falta %04d_%s.up.sql", m.Version, m.Nombre)
+		}
+		migraciones = append(migraciones, *m)
+	}
+	sort.Slice(migraciones, func(i, j int) bool { return migraciones[i].Version < migraciones[j].Version })
+
+	return migraciones, nil
+}
+
+// sentenciasSQL separa un archivo en sentencias terminadas en ";" al final de
+// línea, porque el driver de MySQL no ejecuta varias sentencias en un Exec.
+func sentenciasSQL(contenido string) []string {
+	var sentencias []string
+	var actual strings.Builder
+
+	for _, linea := range strings.Split(contenido, "\n") {
+		recortada := strings.TrimSpace(linea)
+		if recortada == "" || strings.HasPrefix(recortada, "--") {
+			continue
+		}
+		actual.WriteString(linea)
+		actual.WriteString("\n")
+		if strings.HasSuffix(recortada, ";") {
+			sentencias = append(sentencias, strings.TrimSpace(actual.String()))
+			actual.Reset()
+		}
+	}
+
+	if resto := strings.TrimSpace(actual.String()); resto != "" {
+		sentencias = append(sentencias, resto)
+	}
+
+	return sentencias
+}
+
+func crearTablaMigraciones(ctx context.Context, db *sql.DB) error {
+	if _, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migraciones (
+		version INTEGER NOT NULL PRIMARY KEY,
+		nombre VARCHAR(255) NOT NULL,
+		aplicada_en TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`); err != nil {
+		return fmt.Errorf("crearTablaMigraciones %s", err)
+	}
+	return nil
+}
+
+func versionesAplicadas(ctx context.Context, db *sql.DB) (map[int]time.Time, error) {
+	if err := crearTablaMigraciones(ctx, db); err != nil {
+		return nil, err
+	}
+
+	filas, err := db.QueryContext(ctx, "SELECT version, aplicada_en FROM schema_migraciones")
+	if err != nil {
+		return nil, fmt.Errorf("versionesAplicadas %s", err)
+	}
+	defer filas.Close()
+
+	aplicadas := map[int]time.Time{}
+	for filas.Next() {
+		var version int
+		var aplicadaEn time.Time
+		if err := filas.Scan(&version, &aplicadaEn); err != nil {
+			return nil, fmt.Errorf("versionesAplicadas %s", err)
+		}
+		aplicadas[version] = aplicadaEn
+	}
+
+	return aplicadas, filas.Err()
+}
+
+// ejecutarMigracion corre las sentencias y registra (o borra) la versión en una
+// transacción. En MySQL los cambios de esquema se confirman igualmente uno a
+// uno, así que una migración fallida puede quedar a medias.
+func ejecutarMigracion(ctx context.Context, db *sql.DB, m migracion, subir bool) error {
+	contenido, registro := m.Subir, "INSERT INTO schema_migraciones (version, nombre) VALUES (?, ?)"
+	args := []any{m.Version, m.Nombre}
+	if !subir {
+		contenido, registro = m.Bajar, "DELETE FROM schema_migraciones WHERE version = ?"
+		args = args[:1]
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("ejecutarMigracion %04d %s", m.Version, err)
+	}
+	defer tx.Rollback()
+
+	for _, sentencia := range sentenciasSQL(contenido) {
+		if _, err := tx.ExecContext(ctx, sentencia); err != nil {
+			return fmt.Errorf("ejecutarMigracion %04d_%s %s", m.Version, m.Nombre, err)
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, registro, args...); err != nil {
+		return fmt.Errorf("ejecutarMigracion %04d %s", m.Version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("ejecutarMigracion %04d %s", m.Version, err)
+	}
+
+	return nil
+}
+
+// migrarArriba aplica en orden todas las migraciones pendientes y devuelve las
+// que aplicó.
+func migrarArriba(ctx context.Context, db *sql.DB, driver string) ([]migracion, error) {
+	migraciones, err := cargarMigraciones(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	aplicadas, err := versionesAplicadas(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var nuevas []migracion
+	for _, m := range migraciones {
+		if _, ok := aplicadas[m.Version]; ok {
+			continue
+		}
+		if err := ejecutarMigracion(ctx, db, m, true); err != nil {
+			return nuevas, err
+		}
+		nuevas = append(nuevas, m)
+	}
+
+	return nuevas, nil
+}
+
+// migrarAbajo revierte las últimas `pasos` migraciones aplicadas.
+func migrarAbajo(ctx context.Context, db *sql.DB, driver string, pasos int) ([]migracion, error) {
+	migraciones, err := cargarMigraciones(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	aplicadas, err := versionesAplicadas(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	var revertidas []migracion
+	for i := len(migraciones) - 1; i >= 0 && len(revertidas) < pasos; i-- {
+		m := migraciones[i]
+		if _, ok := aplicadas[m.Version]; !ok {
+			continue
+		}
+		if m.Bajar == "" {
+			return revertidas, fmt.Errorf("migrarAbajo la migración %04d_%s no tiene .down.sql", m.Version, m.Nombre)
+		}
+		if err := ejecutarMigracion(ctx, db, m, false); err != nil {
+			return revertidas, err
+		}
+		revertidas = append(revertidas, m)
+	}
+
+	return revertidas, nil
+}
+
+func estadoDeMigraciones(ctx context.Context, db *sql.DB, driver string) ([]estadoMigracion, error) {
+	migraciones, err := cargarMigraciones(driver)
+	if err != nil {
+		return nil, err
+	}
+
+	aplicadas, err := versionesAplicadas(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	estados := make([]estadoMigracion, 0, len(migraciones))
+	for _, m := range migraciones {
+		estado := estadoMigracion{Version: m.Version, Nombre: m.Nombre}
+		if aplicadaEn, ok := aplicadas[m.Version]; ok {
+			estado.AplicadaEn = sql.NullTime{Time: aplicadaEn, Valid: true}
+		}
+		estados = append(estados, estado)
+	}
+
+	return estados, nil
+}
@@ -0,0 +1,35 @@
+-- Las tablas ya existían en producción antes de las migraciones, por eso se
+-- crean solo si hacen falta.
+CREATE TABLE IF NOT EXISTS Familias (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_text VARCHAR(64) NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    miembro_principal BIGINT NOT NULL,
+    nombre_invitacion VARCHAR(255) NOT NULL,
+    UNIQUE KEY uq_familias_id_text (id_text)
+);
+
+CREATE TABLE IF NOT EXISTS Invitados (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_text VARCHAR(64) NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    nombre_invitacion VARCHAR(255) NOT NULL,
+    asiste TINYINT(1) NULL,
+    id_familia BIGINT NULL,
+    UNIQUE KEY uq_invitados_id_text (id_text),
+    KEY idx_invitados_id_familia (id_familia)
+);
+
+CREATE TABLE IF NOT EXISTS Canciones (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_invitado VARCHAR(64) NOT NULL,
+    fecha DATETIME NOT NULL,
+    nombre_cancion VARCHAR(255) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Mensajes (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_invitado VARCHAR(64) NOT NULL,
+    fecha DATETIME NOT NULL,
+    contenido TEXT NOT NULL
+);
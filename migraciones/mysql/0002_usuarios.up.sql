@@ -0,0 +1,30 @@
+CREATE TABLE Usuarios (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    nombre VARCHAR(100) NOT NULL,
+    hash_clave VARCHAR(255) NOT NULL,
+    rol VARCHAR(32) NOT NULL,
+    creado_en DATETIME NOT NULL,
+    UNIQUE KEY uq_usuarios_nombre (nombre)
+);
+
+-- Las sesiones y los tokens se guardan solo como hash SHA-256; el valor en
+-- claro lo conoce únicamente quien lo recibió.
+CREATE TABLE Sesiones (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_usuario BIGINT NOT NULL,
+    hash_token CHAR(64) NOT NULL,
+    expira_en BIGINT NOT NULL,
+    UNIQUE KEY uq_sesiones_hash_token (hash_token),
+    KEY idx_sesiones_id_usuario (id_usuario)
+);
+
+CREATE TABLE TokensApi (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_usuario BIGINT NOT NULL,
+    nombre VARCHAR(100) NOT NULL,
+    hash_token CHAR(64) NOT NULL,
+    creado_en DATETIME NOT NULL,
+    revocado_en DATETIME NULL,
+    UNIQUE KEY uq_tokens_api_hash_token (hash_token),
+    KEY idx_tokens_api_id_usuario (id_usuario)
+);
@@ -0,0 +1,13 @@
+-- Cada invitado y cada familia tiene como mucho un token vigente; los tokens
+-- revocados se conservan para que no se vuelvan a emitir solos.
+CREATE TABLE TokensInvitacion (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    token VARCHAR(64) NOT NULL,
+    tipo VARCHAR(16) NOT NULL,
+    id_destino BIGINT NOT NULL,
+    creado_en DATETIME NOT NULL,
+    expira_en BIGINT NULL,
+    revocado_en DATETIME NULL,
+    UNIQUE KEY uq_tokens_invitacion_token (token),
+    KEY idx_tokens_invitacion_destino (tipo, id_destino)
+);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS InvitacionesEvento;
+DROP TABLE IF EXISTS Eventos;
@@ -0,0 +1,19 @@
+-- Cada evento de la boda (civil, iglesia, recepción...) tiene su propia lista
+-- de invitados y su propia respuesta. fecha es un timestamp Unix.
+CREATE TABLE Eventos (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_text VARCHAR(64) NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    lugar VARCHAR(255) NOT NULL,
+    fecha BIGINT NULL,
+    orden INT NOT NULL,
+    UNIQUE KEY uq_eventos_id_text (id_text)
+);
+
+CREATE TABLE InvitacionesEvento (
+    id_evento BIGINT NOT NULL,
+    id_invitado BIGINT NOT NULL,
+    asiste TINYINT(1) NULL,
+    PRIMARY KEY (id_evento, id_invitado),
+    KEY idx_invitaciones_evento_id_invitado (id_invitado)
+);
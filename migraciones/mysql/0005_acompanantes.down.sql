@@ -0,0 +1,6 @@
+DELETE FROM Invitados WHERE id_anfitrion IS NOT NULL;
+ALTER TABLE Invitados
+    DROP KEY idx_invitados_id_anfitrion,
+    DROP COLUMN acompanantes,
+    DROP COLUMN id_anfitrion;
+ALTER TABLE Familias DROP COLUMN acompanantes;
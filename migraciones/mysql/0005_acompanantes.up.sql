@@ -0,0 +1,8 @@
+-- acompanantes es cuántos acompañantes sin nombre puede traer el invitado o,
+-- en Familias, cuántos comparten todos sus miembros. id_anfitrion marca a los
+-- acompañantes que nombró un invitado al responder.
+ALTER TABLE Invitados
+    ADD COLUMN acompanantes INT NOT NULL DEFAULT 0,
+    ADD COLUMN id_anfitrion BIGINT NULL,
+    ADD KEY idx_invitados_id_anfitrion (id_anfitrion);
+ALTER TABLE Familias ADD COLUMN acompanantes INT NOT NULL DEFAULT 0;
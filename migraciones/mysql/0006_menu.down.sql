@@ -0,0 +1,6 @@
+ALTER TABLE Invitados
+    DROP COLUMN id_platillo,
+    DROP COLUMN restricciones,
+    DROP COLUMN alergias,
+    DROP COLUMN mesa;
+DROP TABLE IF EXISTS Platillos;
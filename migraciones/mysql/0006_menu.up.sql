@@ -0,0 +1,18 @@
+-- Platillos son las opciones del menú. Cada invitado que acepta elige uno en
+-- id_platillo; restricciones son sus etiquetas separadas por comas
+-- (vegetariano, sin gluten...) y alergias, lo que escriba. mesa la asigna la
+-- pareja, para el reporte del catering.
+CREATE TABLE Platillos (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    id_text VARCHAR(64) NOT NULL,
+    nombre VARCHAR(255) NOT NULL,
+    descripcion VARCHAR(500) NOT NULL,
+    orden INT NOT NULL,
+    UNIQUE KEY uq_platillos_id_text (id_text)
+);
+
+ALTER TABLE Invitados
+    ADD COLUMN id_platillo BIGINT NULL,
+    ADD COLUMN restricciones VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN alergias VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN mesa VARCHAR(64) NOT NULL DEFAULT '';
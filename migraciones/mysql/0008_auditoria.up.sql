@@ -0,0 +1,29 @@
+-- Auditoria es el historial de cambios: respuestas, canciones, mensajes y
+-- ediciones de la gestión. Solo se agregan filas, nunca se modifican. Los
+-- invitados y las familias se guardan por id_text, sin claves foráneas, para
+-- que el historial sobreviva cuando se eliminan; id_familia es la familia del
+-- invitado al momento del cambio. autor_tipo es usuario, invitado, familia o
+-- sistema; autor, el nombre del usuario o el id_text de la invitación con la
+-- que se respondió, y huella, el inicio del SHA-256 de su token, que no sirve
+-- para responder. Los cambios a eventos, platillos, usuarios y tokens de API
+-- llevan recurso, el tipo, e id_recurso, su id_text o id. anterior y nuevo
+-- son JSON; NULL significa que no aplica. fecha es un timestamp Unix.
+CREATE TABLE Auditoria (
+    id BIGINT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    fecha BIGINT NOT NULL,
+    accion VARCHAR(64) NOT NULL,
+    id_invitado VARCHAR(64) NULL,
+    id_familia VARCHAR(64) NULL,
+    recurso VARCHAR(16) NULL,
+    id_recurso VARCHAR(64) NULL,
+    autor_tipo VARCHAR(16) NOT NULL,
+    autor VARCHAR(255) NOT NULL,
+    huella VARCHAR(16) NOT NULL DEFAULT '',
+    anterior TEXT NULL,
+    nuevo TEXT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent VARCHAR(512) NOT NULL DEFAULT '',
+    KEY idx_auditoria_invitado (id_invitado),
+    KEY idx_auditoria_familia (id_familia),
+    KEY idx_auditoria_recurso (recurso, id_recurso)
+);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS Mensajes;
+DROP TABLE IF EXISTS Canciones;
+DROP TABLE IF EXISTS Invitados;
+DROP TABLE IF EXISTS Familias;
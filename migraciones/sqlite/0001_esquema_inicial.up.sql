@@ -0,0 +1,32 @@
+CREATE TABLE IF NOT EXISTS Familias (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_text TEXT NOT NULL UNIQUE,
+    nombre TEXT NOT NULL,
+    miembro_principal INTEGER NOT NULL,
+    nombre_invitacion TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Invitados (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_text TEXT NOT NULL UNIQUE,
+    nombre TEXT NOT NULL,
+    nombre_invitacion TEXT NOT NULL,
+    asiste INTEGER NULL,
+    id_familia INTEGER NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_invitados_id_familia ON Invitados (id_familia);
+
+CREATE TABLE IF NOT EXISTS Canciones (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_invitado TEXT NOT NULL,
+    fecha DATETIME NOT NULL,
+    nombre_cancion TEXT NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS Mensajes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_invitado TEXT NOT NULL,
+    fecha DATETIME NOT NULL,
+    contenido TEXT NOT NULL
+);
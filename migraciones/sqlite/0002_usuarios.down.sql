@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS TokensApi;
+DROP TABLE IF EXISTS Sesiones;
+DROP TABLE IF EXISTS Usuarios;
@@ -0,0 +1,29 @@
+CREATE TABLE Usuarios (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    nombre TEXT NOT NULL UNIQUE,
+    hash_clave TEXT NOT NULL,
+    rol TEXT NOT NULL,
+    creado_en DATETIME NOT NULL
+);
+
+-- Las sesiones y los tokens se guardan solo como hash SHA-256; el valor en
+-- claro lo conoce únicamente quien lo recibió.
+CREATE TABLE Sesiones (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_usuario INTEGER NOT NULL,
+    hash_token TEXT NOT NULL UNIQUE,
+    expira_en INTEGER NOT NULL
+);
+
+CREATE INDEX idx_sesiones_id_usuario ON Sesiones (id_usuario);
+
+CREATE TABLE TokensApi (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_usuario INTEGER NOT NULL,
+    nombre TEXT NOT NULL,
+    hash_token TEXT NOT NULL UNIQUE,
+    creado_en DATETIME NOT NULL,
+    revocado_en DATETIME NULL
+);
+
+CREATE INDEX idx_tokens_api_id_usuario ON TokensApi (id_usuario);
@@ -0,0 +1,13 @@
+-- Cada invitado y cada familia tiene como mucho un token vigente; los tokens
+-- revocados se conservan para que no se vuelvan a emitir solos.
+CREATE TABLE TokensInvitacion (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    token TEXT NOT NULL UNIQUE,
+    tipo TEXT NOT NULL,
+    id_destino INTEGER NOT NULL,
+    creado_en DATETIME NOT NULL,
+    expira_en INTEGER NULL,
+    revocado_en DATETIME NULL
+);
+
+CREATE INDEX idx_tokens_invitacion_destino ON TokensInvitacion (tipo, id_destino);
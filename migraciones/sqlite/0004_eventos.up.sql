@@ -0,0 +1,19 @@
+-- Cada evento de la boda (civil, iglesia, recepción...) tiene su propia lista
+-- de invitados y su propia respuesta. fecha es un timestamp Unix.
+CREATE TABLE Eventos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_text TEXT NOT NULL UNIQUE,
+    nombre TEXT NOT NULL,
+    lugar TEXT NOT NULL,
+    fecha INTEGER NULL,
+    orden INTEGER NOT NULL
+);
+
+CREATE TABLE InvitacionesEvento (
+    id_evento INTEGER NOT NULL,
+    id_invitado INTEGER NOT NULL,
+    asiste INTEGER NULL,
+    PRIMARY KEY (id_evento, id_invitado)
+);
+
+CREATE INDEX idx_invitaciones_evento_id_invitado ON InvitacionesEvento (id_invitado);
@@ -0,0 +1,5 @@
+DELETE FROM Invitados WHERE id_anfitrion IS NOT NULL;
+DROP INDEX IF EXISTS idx_invitados_id_anfitrion;
+ALTER TABLE Invitados DROP COLUMN acompanantes;
+ALTER TABLE Invitados DROP COLUMN id_anfitrion;
+ALTER TABLE Familias DROP COLUMN acompanantes;
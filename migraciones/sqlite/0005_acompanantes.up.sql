@@ -0,0 +1,8 @@
+-- acompanantes es cuántos acompañantes sin nombre puede traer el invitado o,
+-- en Familias, cuántos comparten todos sus miembros. id_anfitrion marca a los
+-- acompañantes que nombró un invitado al responder.
+ALTER TABLE Invitados ADD COLUMN acompanantes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE Invitados ADD COLUMN id_anfitrion INTEGER NULL;
+ALTER TABLE Familias ADD COLUMN acompanantes INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_invitados_id_anfitrion ON Invitados (id_anfitrion);
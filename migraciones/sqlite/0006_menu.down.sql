@@ -0,0 +1,5 @@
+ALTER TABLE Invitados DROP COLUMN id_platillo;
+ALTER TABLE Invitados DROP COLUMN restricciones;
+ALTER TABLE Invitados DROP COLUMN alergias;
+ALTER TABLE Invitados DROP COLUMN mesa;
+DROP TABLE IF EXISTS Platillos;
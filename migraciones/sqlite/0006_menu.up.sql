@@ -0,0 +1,16 @@
+-- Platillos son las opciones del menú. Cada invitado que acepta elige uno en
+-- id_platillo; restricciones son sus etiquetas separadas por comas
+-- (vegetariano, sin gluten...) y alergias, lo que escriba. mesa la asigna la
+-- pareja, para el reporte del catering.
+CREATE TABLE Platillos (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    id_text TEXT NOT NULL UNIQUE,
+    nombre TEXT NOT NULL,
+    descripcion TEXT NOT NULL,
+    orden INTEGER NOT NULL
+);
+
+ALTER TABLE Invitados ADD COLUMN id_platillo INTEGER NULL;
+ALTER TABLE Invitados ADD COLUMN restricciones TEXT NOT NULL DEFAULT '';
+ALTER TABLE Invitados ADD COLUMN alergias TEXT NOT NULL DEFAULT '';
+ALTER TABLE Invitados ADD COLUMN mesa TEXT NOT NULL DEFAULT '';
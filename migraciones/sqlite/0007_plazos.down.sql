@@ -0,0 +1,2 @@
+ALTER TABLE Eventos DROP COLUMN limite_rsvp;
+ALTER TABLE Invitados DROP COLUMN prorroga_rsvp;
@@ -0,0 +1,5 @@
+-- limite_rsvp es la fecha límite para responder a un evento, que reemplaza a
+-- la global. prorroga_rsvp es la que la pareja le da a un invitado en
+-- particular y vale para todo. Ambas son timestamps Unix.
+ALTER TABLE Eventos ADD COLUMN limite_rsvp INTEGER NULL;
+ALTER TABLE Invitados ADD COLUMN prorroga_rsvp INTEGER NULL;
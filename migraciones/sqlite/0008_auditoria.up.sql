@@ -0,0 +1,30 @@
+-- Auditoria es el historial de cambios: respuestas, canciones, mensajes y
+-- ediciones de la gestión. Solo se agregan filas, nunca se modifican. Los
+-- invitados y las familias se guardan por id_text, sin claves foráneas, para
+-- que el historial sobreviva cuando se eliminan; id_familia es la familia del
+-- invitado al momento del cambio. autor_tipo es usuario, invitado, familia o
+-- sistema; autor, el nombre del usuario o el id_text de la invitación con la
+-- que se respondió, y huella, el inicio del SHA-256 de su token, que no sirve
+-- para responder. Los cambios a eventos, platillos, usuarios y tokens de API
+-- llevan recurso, el tipo, e id_recurso, su id_text o id. anterior y nuevo
+-- son JSON; NULL significa que no aplica. fecha es un timestamp Unix.
+CREATE TABLE Auditoria (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    fecha INTEGER NOT NULL,
+    accion TEXT NOT NULL,
+    id_invitado TEXT NULL,
+    id_familia TEXT NULL,
+    recurso TEXT NULL,
+    id_recurso TEXT NULL,
+    autor_tipo TEXT NOT NULL,
+    autor TEXT NOT NULL,
+    huella TEXT NOT NULL DEFAULT '',
+    anterior TEXT NULL,
+    nuevo TEXT NULL,
+    ip TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX idx_auditoria_invitado ON Auditoria (id_invitado);
+CREATE INDEX idx_auditoria_familia ON Auditoria (id_familia);
+CREATE INDEX idx_auditoria_recurso ON Auditoria (recurso, id_recurso);
@@ -0,0 +1 @@
+ALTER TABLE Eventos DROP COLUMN sin_menu;
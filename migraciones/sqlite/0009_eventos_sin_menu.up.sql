@@ -0,0 +1,3 @@
+-- sin_menu marca los eventos en los que no se sirve la comida, como el civil,
+-- para que aceptar solo esos no cuente en el reporte de catering.
+ALTER TABLE Eventos ADD COLUMN sin_menu INTEGER NOT NULL DEFAULT 0;
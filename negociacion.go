@@ -0,0 +1,67 @@
+package main
+
+import (
+	"github.com/gin-gonic/gin"
+)
+
+// Vistas HTML de un invitado o una familia. fila trae los botones para
+// responder; presentacion, el saludo de la invitación. tabla es la de
+// respuestas, que tiene una sola, y formulario, el campo de canciones o
+// mensajes.
+const (
+	vistaFila         = "fila"
+	vistaPresentacion = "presentacion"
+	vistaTabla        = "tabla"
+	vistaFormulario   = "formulario"
+)
+
+const claveVistaCtx = "vista"
+
+// quiereHTML decide la representación de la respuesta: /api/v1 siempre es
+// JSON, htmx siempre recibe HTML y el resto según Accept. Sin Accept, o con
+// */*, es JSON, así los scripts no tienen que pedirlo.
+func quiereHTML(gc *gin.Context) bool {
+	if esApiV1(gc) {
+		return false
+	}
+	if gc.GetHeader("HX-Request") == "true" {
+		return true
+	}
+	if _, ok := gc.Get(claveVistaCtx); ok {
+		return true
+	}
+	return gc.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+}
+
+// vistaPedida devuelve la vista que fijó una ruta de compatibilidad o la del
+// parámetro ?vista, con fila por defecto.
+func vistaPedida(gc *gin.Context) string {
+	if vista := gc.GetString(claveVistaCtx); vista != "" {
+		return vista
+	}
+	if gc.Query("vista") == vistaPresentacion {
+		return vistaPresentacion
+	}
+	return vistaFila
+}
+
+// responder envía datos como JSON o la plantilla con vista como HTML, según
+// quiereHTML. Vary evita que un proxy sirva una representación por la otra.
+func (s *servidor) responder(gc *gin.Context, status int, datos any, plantilla string, vista any) {
+	gc.Writer.Header().Add("Vary", "Accept, HX-Request")
+	if quiereHTML(gc) {
+		s.html(gc, status, plantilla, vista)
+		return
+	}
+	s.json(gc, status, datos)
+}
+
+// fijarVista convierte una ruta vieja, que siempre devolvía un fragmento HTML,
+// en un alias del recurso con la vista fija. Va primero para que también los
+// errores de los middlewares lleguen como HTML.
+func fijarVista(vista string) gin.HandlerFunc {
+	return func(gc *gin.Context) {
+		gc.Set(claveVistaCtx, vista)
+		gc.Next()
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Quién puede usar una ruta de /api/v1; define la seguridad en el documento.
+const (
+	accesoPublico          = "publico"
+	accesoInvitado         = "invitado"
+	accesoLectura          = "lectura"
+	accesoGestion          = "gestion"
+	accesoPareja           = "pareja"
+	accesoGestionOInvitado = "gestion-o-invitado"
+)
+
+var descripcionAcceso = map[string]string{
+	accesoPublico:          "No requiere credenciales.",
+	accesoInvitado:         "Sin credenciales; el token del enlace va en el cuerpo.",
+	accesoLectura:          "Requiere sesión o token de API con rol pareja, planeador o proveedor.",
+	accesoGestion:          "Requiere sesión o token de API con rol pareja o planeador.",
+	accesoPareja:           "Requiere sesión o token de API con rol pareja.",
+	accesoGestionOInvitado: "Con credenciales requiere rol pareja o planeador y :id es el id_text; sin ellas :id es el token del enlace.",
+}
+
+// rutaApi es una ruta de /api/v1 junto con lo que hace falta para documentarla.
+type rutaApi struct {
+	Metodo  string
+	Ruta    string
+	Resumen string
+	Acceso  string
+	// Banderas son parámetros de consulta que valen true o false.
+	Banderas []string
+	// Peticion y Respuesta son valores del tipo del cuerpo; nil si no hay.
+	Peticion  any
+	Respuesta any
+	// Status es el código cuando todo sale bien; 200 si es cero.
+	Status   int
+	Handlers []gin.HandlerFunc
+}
+
+// documentoOpenApi arma el documento OpenAPI 3 de las rutas. Los esquemas
+// salen por reflexión de los tipos de Peticion y Respuesta, con los nombres
+// de sus etiquetas json.
+func documentoOpenApi(rutas []rutaApi, urlBase string) map[string]any {
+	e := esquemas{componentes: map[string]any{}}
+	esquemaError := e.de(reflect.TypeOf(RespuestaError{}))
+
+	caminos := map[string]any{}
+	for _, r := range rutas {
+		camino, parametros := caminoOpenApi(r.Ruta)
+		for _, bandera := range r.Banderas {
+			parametros = append(parametros, map[string]any{"name": bandera, "in": "query", "schema": map[string]any{"type": "boolean"}})
+		}
+
+		status := r.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		exito := map[string]any{"description": http.StatusText(status)}
+		if r.Respuesta != nil {
+			exito["content"] = contenidoJSON(e.de(reflect.TypeOf(r.Respuesta)))
+		}
+
+		operacion := map[string]any{
+			"operationId": idOperacion(r.Metodo, r.Ruta),
+			"summary":     r.Resumen,
+			"description": descripcionAcceso[r.Acceso],
+			"tags":        []string{strings.Split(strings.TrimPrefix(r.Ruta, "/"), "/")[0]},
+			"responses": map[string]any{
+				strconv.Itoa(status): exito,
+				"default":            map[string]any{"description": "Error", "content": contenidoJSON(esquemaError)},
+			},
+		}
+		if len(parametros) > 0 {
+			operacion["parameters"] = parametros
+		}
+		if r.Peticion != nil {
+			operacion["requestBody"] = map[string]any{"required": true, "content": contenidoJSON(e.de(reflect.TypeOf(r.Peticion)))}
+		}
+		switch r.Acceso {
+		case accesoLectura, accesoGestion, accesoPareja:
+			operacion["security"] = []any{map[string]any{"bearer": []string{}}, map[string]any{"sesion": []string{}}}
+		case accesoGestionOInvitado:
+			operacion["security"] = []any{map[string]any{"bearer": []string{}}, map[string]any{"sesion": []string{}}, map[string]any{}}
+		}
+
+		metodos, ok := caminos[camino].(map[string]any)
+		if !ok {
+			metodos = map[string]any{}
+			caminos[camino] = metodos
+		}
+		metodos[strings.ToLower(r.Metodo)] = operacion
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":       "Wedding backend",
+			"version":     "1.0.0",
+			"description": "API de invitados, familias y respuestas de la boda. Los errores siempre son un RespuestaError.",
+		},
+		"servers": []any{map[string]any{"url": urlBase + prefijoApiV1}},
+		"paths":   caminos,
+		"components": map[string]any{
+			"schemas": e.componentes,
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "description": "Token de API"},
+				"sesion": map[string]any{"type": "apiKey", "in": "cookie", "name": cookieSesion},
+			},
+		},
+	}
+}
+
+func contenidoJSON(esquema map[string]any) map[string]any {
+	return map[string]any{gin.MIMEJSON: map[string]any{"schema": esquema}}
+}
+
+// caminoOpenApi pasa "/invitados/:id" a "/invitados/{id}" con sus parámetros.
+func caminoOpenApi(ruta string) (string, []any) {
+	var parametros []any
+	partes := strings.Split(ruta, "/")
+	for i, parte := range partes {
+		if !strings.HasPrefix(parte, ":") {
+			continue
+		}
+		nombre := strings.TrimPrefix(parte, ":")
+		partes[i] = "{" + nombre + "}"
+		parametros = append(parametros, map[string]any{"name": nombre, "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+	}
+	return strings.Join(partes, "/"), parametros
+}
+
+// idOperacion arma nombres como getInvitadosIdInvitacion para los generadores
+// de clientes.
+func idOperacion(metodo string, ruta string) string {
+	var sb strings.Builder
+	sb.WriteString(strings.ToLower(metodo))
+	for _, parte := range strings.FieldsFunc(ruta, func(r rune) bool { return r == '/' || r == ':' }) {
+		sb.WriteString(mayuscula(parte))
+	}
+	return sb.String()
+}
+
+func mayuscula(s string) string {
+	r := []rune(s)
+	if len(r) > 0 {
+		r[0] = unicode.ToUpper(r[0])
+	}
+	return string(r)
+}
+
+type esquemas struct {
+	componentes map[string]any
+}
+
+// de devuelve el esquema de t. Las estructuras se registran en componentes y
+// se referencian por nombre.
+func (e *esquemas) de(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+	// Un json.RawMessage puede ser cualquier valor.
+	if t == reflect.TypeOf(json.RawMessage{}) {
+		return map[string]any{}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		esquema := e.de(t.Elem())
+		if _, ok := esquema["$ref"]; ok {
+			return map[string]any{"allOf": []any{esquema}, "nullable": true}
+		}
+		esquema["nullable"] = true
+		return esquema
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": e.de(t.Elem())}
+	case reflect.Struct:
+		nombre := mayuscula(t.Name())
+		if _, ok := e.componentes[nombre]; !ok {
+			// Se reserva antes de recorrer los campos por si el tipo se
+			// contiene a sí mismo.
+			e.componentes[nombre] = map[string]any{}
+			e.componentes[nombre] = e.objeto(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + nombre}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Map:
+		return map[string]any{"type": "object"}
+	}
+	return map[string]any{}
+}
+
+func (e *esquemas) objeto(t reflect.Type) map[string]any {
+	propiedades := map[string]any{}
+	var requeridos []string
+
+	for i := 0; i < t.NumField(); i++ {
+		campo := t.Field(i)
+		if !campo.IsExported() {
+			continue
+		}
+		nombre, opciones, _ := strings.Cut(campo.Tag.Get("json"), ",")
+		if nombre == "-" {
+			continue
+		}
+		if nombre == "" {
+			nombre = campo.Name
+		}
+
+		propiedades[nombre] = e.de(campo.Type)
+		if !strings.Contains(opciones, "omitempty") && campo.Type.Kind() != reflect.Pointer && campo.Type.Kind() != reflect.Interface {
+			requeridos = append(requeridos, nombre)
+		}
+	}
+
+	objeto := map[string]any{"type": "object", "properties": propiedades}
+	if len(requeridos) > 0 {
+		objeto["required"] = requeridos
+	}
+	return objeto
+}
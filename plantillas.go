@@ -0,0 +1,159 @@
+package main
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//go:embed plantillas/*.html
+var plantillasFS embed.FS
+
+// plantillas guarda los fragmentos HTML que devuelven los handlers. Las
+// embebidas se pueden reemplazar con archivos .html de un directorio que
+// redefinan los mismos {{define}}.
+type plantillas struct {
+	dir     string
+	urlBase string
+	// recargar vuelve a leer el directorio en cada render, para ajustar el
+	// diseño sin reiniciar el servidor.
+	recargar bool
+	conjunto *template.Template
+}
+
+func cargarPlantillas(dir string, recargar bool, urlBase string) (*plantillas, error) {
+	p := &plantillas{dir: dir, urlBase: urlBase, recargar: recargar && dir != ""}
+
+	conjunto, err := p.parsear()
+	if err != nil {
+		return nil, err
+	}
+	p.conjunto = conjunto
+
+	return p, nil
+}
+
+func (p *plantillas) parsear() (*template.Template, error) {
+	conjunto, err := template.New("").Funcs(template.FuncMap{"url": p.url, "fecha": fecha}).ParseFS(plantillasFS, "plantillas/*.html")
+	if err != nil {
+		return nil, fmt.Errorf("parsear %s", err)
+	}
+
+	if p.dir == "" {
+		return conjunto, nil
+	}
+
+	archivos, err := filepath.Glob(filepath.Join(p.dir, "*.html"))
+	if err != nil {
+		return nil, fmt.Errorf("parsear %s", err)
+	}
+	if len(archivos) == 0 {
+		return conjunto, nil
+	}
+
+	conjunto, err = conjunto.ParseFiles(archivos...)
+	if err != nil {
+		return nil, fmt.Errorf("parsear %s", err)
+	}
+	return conjunto, nil
+}
+
+// url antepone la URL base a una ruta del backend. Sin URL base la ruta
+// queda relativa. En las plantillas se usa como {{url "/asistencia/aceptar"}}.
+func (p *plantillas) url(ruta string) string {
+	return p.urlBase + ruta
+}
+
+// fecha da formato a las fechas que ven los invitados.
+func fecha(t time.Time) string {
+	return t.Format("02/01/2006 15:04")
+}
+
+func (p *plantillas) ejecutar(nombre string, datos any) ([]byte, error) {
+	conjunto := p.conjunto
+	if p.recargar {
+		var err error
+		if conjunto, err = p.parsear(); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := conjunto.ExecuteTemplate(&buf, nombre, datos); err != nil {
+		return nil, fmt.Errorf("ejecutar %s", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// html responde con el fragmento nombre. Se renderiza completo antes de
+// escribir, así un error en la plantilla termina en 500 y no en HTML a medias.
+func (s *servidor) html(gc *gin.Context, status int, nombre string, datos any) {
+	contenido, err := s.plantillas.ejecutar(nombre, datos)
+	if err != nil {
+		log.Printf("html %s", err)
+		gc.Status(http.StatusInternalServerError)
+		return
+	}
+
+	gc.Data(status, "text/html; charset=utf-8", contenido)
+}
+
+// filaInvitadoVista son los datos de la plantilla fila-invitado. Con eventos
+// la fila trae un par de botones por evento en lugar del par general, con
+// Acompanantes, el formulario para nombrarlos y con Menu, el del menú. Plazo
+// es el de la respuesta general; el de cada evento sale de plazo y ahora.
+type filaInvitadoVista struct {
+	Invitado     InvitadoResp
+	Token        string
+	Eventos      []AsistenciaEvento
+	Acompanantes *Acompanantes
+	Menu         *menuVista
+	Plazo        estadoPlazo
+
+	plazo plazoRsvp
+	ahora time.Time
+}
+
+func (f filaInvitadoVista) Cerrados() botonesCerradosVista {
+	return botonesCerradosVista{Clave: f.Token, Asiste: f.Invitado.Asiste, Plazo: f.Plazo}
+}
+
+func (f filaInvitadoVista) FormularioAcompanantes() acompanantesVista {
+	return acompanantesVista{Token: f.Token, Acompanantes: *f.Acompanantes}
+}
+
+func (f filaInvitadoVista) BotonesEventos() []botonesEventoVista {
+	botones := make([]botonesEventoVista, 0, len(f.Eventos))
+	for _, asistencia := range f.Eventos {
+		plazo := f.plazo.estado(f.ahora, asistencia.Evento.Limite_rsvp, f.Invitado.Prorroga)
+		botones = append(botones, botonesEvento(f.Token, asistencia, plazo))
+	}
+	return botones
+}
+
+// botonesRespuestaVista son los datos de botones-respuesta. Menu, si la boda
+// tiene menú, viaja con los botones para cambiar el formulario del invitado.
+type botonesRespuestaVista struct {
+	Token  string
+	Asiste bool
+	Menu   *menuVista
+}
+
+type tablaRsvpVista struct {
+	Total         int `json:"total"`
+	Sin_respuesta int `json:"sin_respuesta"`
+	Rechazados    int `json:"rechazados"`
+	Aceptados     int `json:"aceptados"`
+	// Acompanantes son los acompañantes confirmados, ya contados en Aceptados.
+	Acompanantes int `json:"acompanantes"`
+	// Eventos son los mismos totales para cada evento de la boda.
+	Eventos []totalesEvento `json:"eventos"`
+	Filas   []filaRsvp      `json:"filas"`
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const nombreMalicioso = `<script>alert("hola")</script>`
+
+func TestPlantillasEscapanDatos(t *testing.T) {
+	p, err := cargarPlantillas("", false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	casos := []struct {
+		nombre    string
+		plantilla string
+		datos     any
+	}{
+		{"fila de invitado", "fila-invitado", filaInvitadoVista{Invitado: InvitadoResp{Nombre: nombreMalicioso}}},
+		{"fila con botones", "fila-invitado", filaInvitadoVista{Invitado: InvitadoResp{Nombre: nombreMalicioso, Asiste: sql.NullBool{Bool: true, Valid: true}}, Token: `tok" onclick="robar()`}},
+		{"presentación de familia", "presentacion", FamiliasResp{Nombre: nombreMalicioso, Nombre_invitacion: nombreMalicioso}},
+		{"presentación de invitado", "presentacion", InvitadoResp{Nombre: nombreMalicioso, Nombre_invitacion: nombreMalicioso}},
+		{"tabla de respuestas", "tabla-rsvp", tablaRsvpVista{Total: 1, Filas: []filaRsvp{{Nombre: nombreMalicioso, Familia: nombreMalicioso, Asistencia: `x" onmouseover="robar()`}}}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			html, err := p.ejecutar(c.plantilla, c.datos)
+			if err != nil {
+				t.Fatal(err)
+			}
+			salida := string(html)
+			if strings.Contains(salida, "<script>") || strings.Contains(salida, `" onclick="`) || strings.Contains(salida, `" onmouseover="`) {
+				t.Errorf("%s no escapó los datos:\n%s", c.plantilla, salida)
+			}
+			if !strings.Contains(salida, "&lt;script&gt;") {
+				t.Errorf("%s no muestra el nombre escapado:\n%s", c.plantilla, salida)
+			}
+		})
+	}
+}
+
+func TestPlantillasDirectorio(t *testing.T) {
+	dir := t.TempDir()
+	archivo := filepath.Join(dir, "presentacion.html")
+	escribir := func(contenido string) {
+		t.Helper()
+		if err := os.WriteFile(archivo, []byte(contenido), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	escribir(`{{define "presentacion"}}<h2>{{.Nombre}}</h2>{{end}}`)
+
+	casos := []struct {
+		nombre   string
+		dir      string
+		recargar bool
+		// cambio reemplaza la plantilla del directorio después de cargar.
+		cambio string
+		quiere string
+	}{
+		{"embebidas", "", false, "", "<h1 class='nombre-presentacion nombre-principal'>Ana</h1>"},
+		{"el directorio reemplaza", dir, false, "", "<h2>Ana</h2>"},
+		{"sin recargar no relee", dir, false, `{{define "presentacion"}}<h3>{{.Nombre}}</h3>{{end}}`, "<h2>Ana</h2>"},
+		{"recargar relee", dir, true, `{{define "presentacion"}}<h3>{{.Nombre}}</h3>{{end}}`, "<h3>Ana</h3>"},
+		{"directorio vacío", t.TempDir(), false, "", "<h1 class='nombre-presentacion nombre-principal'>Ana</h1>"},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			escribir(`{{define "presentacion"}}<h2>{{.Nombre}}</h2>{{end}}`)
+			p, err := cargarPlantillas(c.dir, c.recargar, "")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.cambio != "" {
+				escribir(c.cambio)
+			}
+
+			html, err := p.ejecutar("presentacion", InvitadoResp{Nombre: "Ana"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(html), c.quiere) {
+				t.Errorf("presentacion = %q, se esperaba %q", html, c.quiere)
+			}
+
+			// Las que el directorio no redefine siguen siendo las embebidas.
+			if _, err := p.ejecutar("fila-invitado", filaInvitadoVista{Invitado: InvitadoResp{Nombre: "Ana"}}); err != nil {
+				t.Errorf("fila-invitado: %v", err)
+			}
+		})
+	}
+}
+
+func TestPlantillasDirectorioInvalido(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rota.html"), []byte(`{{define "presentacion"}}{{.Nombre}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := cargarPlantillas(dir, false, ""); err == nil {
+		t.Error("cargarPlantillas aceptó una plantilla que no compila")
+	}
+}
+
+func TestBotonesUrlBase(t *testing.T) {
+	casos := []struct {
+		nombre    string
+		urlBase   string
+		plantilla string
+		quiere    string
+	}{
+		{"aceptar relativo", "", "boton-aceptar", `hx-post="/asistencia/aceptar"`},
+		{"rechazar relativo", "", "boton-rechazar", `hx-post="/asistencia/rechazar"`},
+		{"aceptar con URL base", "https://wedding.example.com", "boton-aceptar", `hx-post="https://wedding.example.com/asistencia/aceptar"`},
+		{"rechazar con URL base y ruta", "https://example.com/boda", "boton-rechazar", `hx-post="https://example.com/boda/asistencia/rechazar"`},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			p, err := cargarPlantillas("", false, c.urlBase)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			html, err := p.ejecutar(c.plantilla, "tok123")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(string(html), c.quiere) {
+				t.Errorf("%s no trae %s:\n%s", c.plantilla, c.quiere, html)
+			}
+		})
+	}
+}
+
+func TestParsearURLBase(t *testing.T) {
+	casos := []struct {
+		valor  string
+		quiere string
+		falla  bool
+	}{
+		{"", "", false},
+		{"https://wedding-back.fly.dev", "https://wedding-back.fly.dev", false},
+		{"https://wedding-back.fly.dev/", "https://wedding-back.fly.dev", false},
+		{"http://localhost:8080/api", "http://localhost:8080/api", false},
+		{"wedding-back.fly.dev", "", true},
+		{"ftp://wedding-back.fly.dev", "", true},
+		{"https://wedding-back.fly.dev?x=1", "", true},
+	}
+
+	for _, c := range casos {
+		t.Run(c.valor, func(t *testing.T) {
+			urlBase, err := parsearURLBase(c.valor)
+			if (err != nil) != c.falla {
+				t.Fatalf("parsearURLBase(%q) error = %v, se esperaba falla %v", c.valor, err, c.falla)
+			}
+			if urlBase != c.quiere {
+				t.Errorf("parsearURLBase(%q) = %q, se esperaba %q", c.valor, urlBase, c.quiere)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// plazoRsvp es la fecha límite global para responder y la gracia que se deja
+// después, en la que todavía se aceptan cambios con un aviso. Un Limite cero
+// significa que no hay fecha límite.
+type plazoRsvp struct {
+	Limite time.Time
+	Gracia time.Duration
+}
+
+// estadoPlazo es el plazo que aplica a una respuesta en particular. Cierre es
+// cuándo se dejan de aceptar cambios: el límite más la gracia.
+type estadoPlazo struct {
+	Limite    time.Time `json:"limite"`
+	Cierre    time.Time `json:"cierre"`
+	Cerrado   bool      `json:"cerrado"`
+	En_gracia bool      `json:"en_gracia"`
+}
+
+// cargarPlazoRsvp lee LIMITERSVP, una fecha RFC 3339, y GRACIARSVP, una
+// duración como 48h.
+func cargarPlazoRsvp() (plazoRsvp, error) {
+	var plazo plazoRsvp
+
+	if valor := os.Getenv("LIMITERSVP"); valor != "" {
+		limite, err := time.Parse(time.RFC3339, valor)
+		if err != nil {
+			return plazo, fmt.Errorf("LIMITERSVP inválida %q, usa algo como 2026-05-01T23:59:00-06:00", valor)
+		}
+		plazo.Limite = limite
+	}
+
+	if valor := os.Getenv("GRACIARSVP"); valor != "" {
+		gracia, err := time.ParseDuration(valor)
+		if err != nil || gracia < 0 {
+			return plazo, fmt.Errorf("GRACIARSVP inválida %q, usa algo como 48h", valor)
+		}
+		plazo.Gracia = gracia
+	}
+
+	return plazo, nil
+}
+
+// estado calcula el plazo de una respuesta: el límite del evento o el global,
+// con la gracia. La prórroga del invitado solo alarga el plazo: vale, sin
+// gracia, cuando cierra después que el plazo normal, y sin fecha límite no
+// hace falta.
+func (p plazoRsvp) estado(ahora time.Time, limiteEvento *time.Time, prorroga *time.Time) estadoPlazo {
+	var e estadoPlazo
+	switch {
+	case limiteEvento != nil:
+		e.Limite, e.Cierre = *limiteEvento, limiteEvento.Add(p.Gracia)
+	case !p.Limite.IsZero():
+		e.Limite, e.Cierre = p.Limite, p.Limite.Add(p.Gracia)
+	default:
+		return e
+	}
+	if prorroga != nil && prorroga.After(e.Cierre) {
+		e.Limite, e.Cierre = *prorroga, *prorroga
+	}
+
+	e.Cerrado = !ahora.Before(e.Cierre)
+	e.En_gracia = !e.Cerrado && !ahora.Before(e.Limite)
+	return e
+}
+
+// Vigente indica si hay alguna fecha límite.
+func (e estadoPlazo) Vigente() bool {
+	return !e.Limite.IsZero()
+}
+
+// plazoDeInvitado es el plazo para la respuesta general del invitado, o para
+// un evento si limiteEvento no es nil. Si el invitado no existe devuelve el
+// plazo sin prórroga y deja que la respuesta falle después.
+func (s *servidor) plazoDeInvitado(ctx context.Context, idText string, limiteEvento *time.Time) (estadoPlazo, error) {
+	invitado, err := s.invitados.InvitadoPorId(ctx, idText)
+	if err != nil && !errors.Is(err, errInvitadoNoEncontrado) {
+		return estadoPlazo{}, err
+	}
+	return s.plazo.estado(time.Now(), limiteEvento, invitado.Prorroga), nil
+}
+
+// plazoDeRespuesta es el plazo de la respuesta general del invitado, que
+// también se aplica a cada uno de sus eventos: el del primer evento que ya
+// cerró o, si ninguno cerró, el global.
+func (s *servidor) plazoDeRespuesta(ctx context.Context, idText string) (estadoPlazo, error) {
+	invitado, err := s.invitados.InvitadoPorId(ctx, idText)
+	if errors.Is(err, errInvitadoNoEncontrado) {
+		return s.plazo.estado(time.Now(), nil, nil), nil
+	}
+	if err != nil {
+		return estadoPlazo{}, err
+	}
+
+	ahora := time.Now()
+	plazo := s.plazo.estado(ahora, nil, invitado.Prorroga)
+	if plazo.Cerrado {
+		return plazo, nil
+	}
+
+	eventos, err := s.eventos.EventosDeInvitado(ctx, idText)
+	if err != nil {
+		return estadoPlazo{}, err
+	}
+	for _, e := range eventos {
+		if plazoEvento := s.plazo.estado(ahora, e.Evento.Limite_rsvp, invitado.Prorroga); plazoEvento.Cerrado {
+			return plazoEvento, nil
+		}
+	}
+	return plazo, nil
+}
+
+// plazoVencido es el error de responder después del cierre.
+func plazoVencido(plazo estadoPlazo) *errorApi {
+	return &errorApi{
+		Tipo:     tipoPlazoVencido,
+		Mensaje:  "La fecha para responder ya pasó. Si necesitas cambiar tu respuesta, contacta a los novios",
+		Detalles: gin.H{"limite": plazo.Limite, "cierre": plazo.Cierre},
+	}
+}
+
+// botonesCerradosVista son los datos de botones-cerrados: la respuesta que
+// quedó, sin poder cambiarla, y el aviso del plazo.
+type botonesCerradosVista struct {
+	Clave  string
+	Asiste sql.NullBool
+	Plazo  estadoPlazo
+}
+
+// plazoVencidoVista son los datos de plazo-vencido, la respuesta de htmx a
+// un botón que se pulsó después del cierre.
+type plazoVencidoVista struct {
+	Clave string
+	Plazo estadoPlazo
+}
+
+type ProrrogaCommand struct {
+	Hasta *time.Time `json:"hasta"`
+}
+
+// cambiarProrroga deja que un invitado responda después de la fecha límite,
+// hasta la fecha indicada. Sin fecha vuelve a valer el plazo de todos.
+func (s *servidor) cambiarProrroga(gc *gin.Context) {
+	id := gc.Param("id")
+	var cmd ProrrogaCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	invitado, err := s.invitados.CambiarProrroga(gc.Request.Context(), id, cmd.Hasta)
+	if err != nil {
+		s.responderError(gc, errorInvitado(id, err))
+		return
+	}
+
+	s.json(gc, http.StatusOK, invitado)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPlazoRsvpEstado(t *testing.T) {
+	limite := time.Date(2026, 5, 1, 23, 59, 0, 0, time.UTC)
+	gracia := 48 * time.Hour
+	cierre := limite.Add(gracia)
+	en := func(d time.Duration) *time.Time {
+		f := limite.Add(d)
+		return &f
+	}
+
+	global := plazoRsvp{Limite: limite, Gracia: gracia}
+
+	casos := []struct {
+		nombre       string
+		plazo        plazoRsvp
+		ahora        time.Time
+		limiteEvento *time.Time
+		prorroga     *time.Time
+		quiere       estadoPlazo
+	}{
+		{"sin fecha límite", plazoRsvp{}, limite, nil, nil, estadoPlazo{}},
+		{"sin fecha límite ignora la prórroga", plazoRsvp{}, limite, nil, en(time.Hour), estadoPlazo{}},
+		{"antes del límite", global, limite.Add(-time.Hour), nil, nil, estadoPlazo{Limite: limite, Cierre: cierre}},
+		{"en el límite empieza la gracia", global, limite, nil, nil, estadoPlazo{Limite: limite, Cierre: cierre, En_gracia: true}},
+		{"en el cierre", global, cierre, nil, nil, estadoPlazo{Limite: limite, Cierre: cierre, Cerrado: true}},
+		{"límite del evento", global, limite, en(-24 * time.Hour), nil, estadoPlazo{Limite: limite.Add(-24 * time.Hour), Cierre: limite.Add(24 * time.Hour), En_gracia: true}},
+		{"límite del evento sin global", plazoRsvp{Gracia: gracia}, limite.Add(-time.Hour), en(0), nil, estadoPlazo{Limite: limite, Cierre: cierre}},
+		{"prórroga después del cierre", global, cierre, nil, en(72 * time.Hour), estadoPlazo{Limite: limite.Add(72 * time.Hour), Cierre: limite.Add(72 * time.Hour)}},
+		{"prórroga vencida cierra sin gracia", global, limite.Add(72 * time.Hour), nil, en(72 * time.Hour), estadoPlazo{Limite: limite.Add(72 * time.Hour), Cierre: limite.Add(72 * time.Hour), Cerrado: true}},
+		{"prórroga dentro de la gracia no acorta", global, limite.Add(time.Hour), nil, en(24 * time.Hour), estadoPlazo{Limite: limite, Cierre: cierre, En_gracia: true}},
+		{"prórroga anterior al límite no acorta", global, limite.Add(-time.Hour), nil, en(-48 * time.Hour), estadoPlazo{Limite: limite, Cierre: cierre}},
+		{"prórroga anterior al cierre del evento no acorta", global, limite, en(24 * time.Hour), en(48 * time.Hour), estadoPlazo{Limite: limite.Add(24 * time.Hour), Cierre: limite.Add(72 * time.Hour)}},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			e := c.plazo.estado(c.ahora, c.limiteEvento, c.prorroga)
+			if !e.Limite.Equal(c.quiere.Limite) || !e.Cierre.Equal(c.quiere.Cierre) || e.Cerrado != c.quiere.Cerrado || e.En_gracia != c.quiere.En_gracia {
+				t.Errorf("estado() = %+v, se esperaba %+v", e, c.quiere)
+			}
+		})
+	}
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InvitadoPublico es lo que ve un invitado con su enlace: sin los ids internos
+// y con el token con el que responde.
+type InvitadoPublico struct {
+	Token             string `json:"token"`
+	Nombre            string `json:"nombre"`
+	Nombre_invitacion string `json:"nombre_invitacion"`
+	Asiste            *bool  `json:"asiste"`
+	// Eventos trae los eventos a los que está invitado, si la boda los tiene.
+	Eventos []AsistenciaEvento `json:"eventos,omitempty"`
+	// Acompanantes solo viene si puede traer acompañantes o ya nombró alguno.
+	Acompanantes *Acompanantes `json:"acompanantes,omitempty"`
+	// Menu solo viene si la boda tiene menú y el invitado aceptó.
+	Menu *EleccionMenu `json:"menu,omitempty"`
+	// Plazo solo viene si hay fecha límite para responder.
+	Plazo *estadoPlazo `json:"plazo,omitempty"`
+}
+
+type FamiliaPublica struct {
+	Nombre            string            `json:"nombre"`
+	Nombre_invitacion string            `json:"nombre_invitacion"`
+	Invitados         []InvitadoPublico `json:"invitados"`
+}
+
+func invitadoPublico(fila filaInvitadoVista) InvitadoPublico {
+	invitado := fila.Invitado
+	publico := InvitadoPublico{Token: fila.Token, Nombre: invitado.Nombre, Nombre_invitacion: invitado.Nombre_invitacion, Eventos: fila.Eventos, Acompanantes: fila.Acompanantes}
+	if invitado.Asiste.Valid {
+		publico.Asiste = &invitado.Asiste.Bool
+	}
+	if fila.Menu != nil && fila.Menu.Asiste {
+		publico.Menu = &fila.Menu.Eleccion
+	}
+	if fila.Plazo.Vigente() {
+		publico.Plazo = &fila.Plazo
+	}
+	return publico
+}
+
+// filaInvitado junta al invitado con su token y, si puede responder, con sus
+// eventos, sus acompañantes y su menú.
+func (s *servidor) filaInvitado(ctx context.Context, invitado InvitadoResp, token string) (filaInvitadoVista, error) {
+	fila := filaInvitadoVista{Invitado: invitado, Token: token, plazo: s.plazo, ahora: time.Now()}
+	fila.Plazo = s.plazo.estado(fila.ahora, nil, invitado.Prorroga)
+	if token == "" {
+		return fila, nil
+	}
+
+	eventos, err := s.eventos.EventosDeInvitado(ctx, invitado.Id_text)
+	if err != nil {
+		return fila, err
+	}
+	fila.Eventos = eventos
+
+	acompanantes, err := s.invitados.Acompanantes(ctx, invitado.Id_text)
+	if err != nil {
+		return fila, err
+	}
+	if acompanantes.Cupo > 0 || len(acompanantes.Nombres) > 0 {
+		fila.Acompanantes = &acompanantes
+	}
+
+	fila.Menu, err = s.vistaMenu(ctx, invitado, token)
+	return fila, err
+}
+
+// destinoDePeticion resuelve el :id de la petición. La gestión puede usar el
+// id_text o un token; un invitado, solo el token de su enlace.
+func (s *servidor) destinoDePeticion(gc *gin.Context, tipo string) (Invitacion, error) {
+	id := gc.Param("id")
+
+	invitacion, err := s.resolverInvitacion(gc, id, "", tipo)
+	if esGestion(gc) && errors.Is(err, errInvitacionInvalida) {
+		return Invitacion{Tipo: tipo, Id_text: id}, nil
+	}
+	if err != nil {
+		return Invitacion{}, s.errorDestino(gc, tipo, err)
+	}
+	return invitacion, nil
+}
+
+// errorDestino traduce los errores al buscar el destino de :id: la gestión ve
+// el id que pidió y un invitado solo que su enlace no sirve.
+func (s *servidor) errorDestino(gc *gin.Context, tipo string, err error) error {
+	if !esGestion(gc) {
+		return errorEnlace(err)
+	}
+	if tipo == invitacionFamilia {
+		return errorFamilia(gc.Param("id"), err)
+	}
+	return errorInvitado(gc.Param("id"), err)
+}
+
+// getInvitado atiende /invitados/:id para la gestión y para los invitados. En
+// JSON la gestión recibe el invitado completo y el invitado un
+// InvitadoPublico; en HTML la vista fila trae los botones para responder y
+// ?vista=presentacion, el saludo.
+func (s *servidor) getInvitado(gc *gin.Context) {
+	invitacion, err := s.destinoDePeticion(gc, invitacionInvitado)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	ctx := gc.Request.Context()
+
+	invitado, err := s.invitados.InvitadoPorId(ctx, invitacion.Id_text)
+	if err != nil {
+		s.responderError(gc, s.errorDestino(gc, invitacionInvitado, err))
+		return
+	}
+
+	if esGestion(gc) && !quiereHTML(gc) {
+		s.json(gc, http.StatusOK, invitado)
+		return
+	}
+
+	fila, err := s.filaInvitado(ctx, invitado, invitacion.Token)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	if vistaPedida(gc) == vistaPresentacion {
+		s.responder(gc, http.StatusOK, invitadoPublico(fila), "presentacion", invitado)
+		return
+	}
+	s.responder(gc, http.StatusOK, invitadoPublico(fila), "fila-invitado", fila)
+}
+
+// getFamilia atiende /familias/:id como getInvitado. Cada miembro recibe su
+// propio token, así la respuesta de uno no sirve para responder por otro.
+func (s *servidor) getFamilia(gc *gin.Context) {
+	ctx := gc.Request.Context()
+
+	invitacion, err := s.destinoDePeticion(gc, invitacionFamilia)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	familia, err := s.familias.FamiliaPorId(ctx, invitacion.Id_text)
+	if err != nil {
+		s.responderError(gc, s.errorDestino(gc, invitacionFamilia, err))
+		return
+	}
+
+	vista := vistaPedida(gc)
+	if esGestion(gc) && (vista == vistaPresentacion || !quiereHTML(gc)) {
+		s.responder(gc, http.StatusOK, familia, "presentacion", familia)
+		return
+	}
+
+	miembros, err := s.invitados.InvitadosPorFamilia(ctx, invitacion.Id_text)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	publica := FamiliaPublica{Nombre: familia.Nombre, Nombre_invitacion: familia.Nombre_invitacion, Invitados: []InvitadoPublico{}}
+	filas := make([]filaInvitadoVista, 0, len(miembros))
+	for _, invitado := range miembros {
+		token, err := s.tokenMiembro(ctx, invitacion, invitado)
+		if err != nil {
+			s.responderError(gc, err)
+			return
+		}
+		fila, err := s.filaInvitado(ctx, invitado, token)
+		if err != nil {
+			s.responderError(gc, err)
+			return
+		}
+		publica.Invitados = append(publica.Invitados, invitadoPublico(fila))
+		filas = append(filas, fila)
+	}
+
+	if vista == vistaPresentacion {
+		s.responder(gc, http.StatusOK, publica, "presentacion", familia)
+		return
+	}
+	s.responder(gc, http.StatusOK, publica, "filas-invitados", filas)
+}
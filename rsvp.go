@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	asistenciaActualizada = "actualizado"
+	asistenciaDesconocida = "id-desconocido"
+	asistenciaSinCambios  = "sin-cambios"
+)
+
+// ResultadoAsistencia es el resultado de una respuesta. Id_text es el token
+// con el que respondió el invitado y Evento, el evento si respondió a uno.
+type ResultadoAsistencia struct {
+	Id_text   string `json:"id_text"`
+	Evento    string `json:"evento,omitempty"`
+	Resultado string `json:"resultado"`
+}
+
+type RespuestaLote struct {
+	Message    string                `json:"message"`
+	Aplicado   bool                  `json:"aplicado"`
+	Resultados []ResultadoAsistencia `json:"resultados"`
+}
+
+// responderAsistencia registra la respuesta del invitado cuyo token viene en el
+// cuerpo y devuelve los botones actualizados. Si la asistencia ya tenía ese
+// valor responde 204 para que htmx deje los botones como están. En JSON
+// devuelve siempre el ResultadoAsistencia. Como la respuesta pasa a todos los
+// eventos del invitado, se rechaza si el plazo de alguno ya cerró.
+func (s *servidor) responderAsistencia(gc *gin.Context, asiste bool) {
+	var invitado InvitadoId
+
+	if err := gc.ShouldBindJSON(&invitado); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	ctx := gc.Request.Context()
+
+	invitacion, err := s.resolverInvitacion(gc, invitado.Invitado_Id, alcanceRsvp, invitacionInvitado)
+	if err != nil {
+		s.responderError(gc, errorEnlace(err))
+		return
+	}
+
+	plazo, err := s.plazoDeRespuesta(ctx, invitacion.Id_text)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	if plazo.Cerrado {
+		s.responderFormulario(gc, plazoVencido(plazo), "plazo-vencido", plazoVencidoVista{Clave: invitacion.Token, Plazo: plazo})
+		return
+	}
+
+	resultado, err := s.invitados.RegistrarAsistencia(ctx, invitacion.Id_text, asiste)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	switch {
+	case resultado == asistenciaDesconocida:
+		s.responderError(gc, errorEnlace(errInvitacionInvalida))
+	case !quiereHTML(gc):
+		s.json(gc, http.StatusOK, ResultadoAsistencia{Id_text: invitacion.Token, Resultado: resultado})
+	case resultado == asistenciaSinCambios:
+		gc.Status(http.StatusNoContent)
+	default:
+		menu, err := s.menuTrasResponder(ctx, invitacion)
+		if err != nil {
+			s.responderError(gc, err)
+			return
+		}
+		s.html(gc, http.StatusOK, "botones-respuesta", botonesRespuestaVista{Token: invitacion.Token, Asiste: asiste, Menu: menu})
+	}
+}
+
+// responderAsistenciaEvento registra la respuesta del invitado al evento :id y
+// devuelve sus botones para ese evento, igual que responderAsistencia. Después
+// del cierre devuelve los botones deshabilitados con el aviso.
+func (s *servidor) responderAsistenciaEvento(gc *gin.Context, asiste bool) {
+	var invitado InvitadoId
+
+	if err := gc.ShouldBindJSON(&invitado); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	ctx := gc.Request.Context()
+	id := gc.Param("id")
+
+	invitacion, err := s.resolverInvitacion(gc, invitado.Invitado_Id, alcanceRsvp, invitacionInvitado)
+	if err != nil {
+		s.responderError(gc, errorEnlace(err))
+		return
+	}
+
+	evento, err := s.eventos.EventoPorId(ctx, id)
+	if err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	plazo, err := s.plazoDeInvitado(ctx, invitacion.Id_text, evento.Limite_rsvp)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	if plazo.Cerrado {
+		s.responderEventoCerrado(gc, invitacion, evento, plazo)
+		return
+	}
+
+	resultado, err := s.eventos.RegistrarAsistenciaEvento(ctx, invitacion.Id_text, id, asiste)
+	if err != nil {
+		s.responderError(gc, errorEvento(id, err))
+		return
+	}
+
+	switch {
+	case !quiereHTML(gc):
+		s.json(gc, http.StatusOK, ResultadoAsistencia{Id_text: invitacion.Token, Evento: id, Resultado: resultado})
+	case resultado == asistenciaSinCambios:
+		gc.Status(http.StatusNoContent)
+	default:
+		vista := botonesEvento(invitacion.Token, AsistenciaEvento{Evento: evento, Asiste: &asiste}, plazo)
+		if vista.Menu, err = s.menuTrasResponder(ctx, invitacion); err != nil {
+			s.responderError(gc, err)
+			return
+		}
+		s.html(gc, http.StatusOK, "botones-evento", vista)
+	}
+}
+
+// responderEventoCerrado devuelve los botones del evento deshabilitados, con
+// la respuesta que ya tenía el invitado.
+func (s *servidor) responderEventoCerrado(gc *gin.Context, invitacion Invitacion, evento EventoResp, plazo estadoPlazo) {
+	if !quiereHTML(gc) {
+		s.responderError(gc, plazoVencido(plazo))
+		return
+	}
+
+	eventos, err := s.eventos.EventosDeInvitado(gc.Request.Context(), invitacion.Id_text)
+	if err != nil {
+		s.responderError(gc, errorEvento(evento.Id_text, err))
+		return
+	}
+
+	asistencia := AsistenciaEvento{Evento: evento}
+	for _, e := range eventos {
+		if e.Evento.Id_text == evento.Id_text {
+			asistencia = e
+		}
+	}
+	s.responderFormulario(gc, plazoVencido(plazo), "botones-evento", botonesEvento(invitacion.Token, asistencia, plazo))
+}
+
+// maxAsistenciaLote es el largo máximo de la lista de /asistencia/lote, para
+// que una sola petición no pruebe muchos tokens.
+const maxAsistenciaLote = 20
+
+// actualizarAsistenciaLotePorToken traduce los tokens de la lista, que llegan
+// en el campo id_text, antes de aplicarla. Si alguno no es válido no se aplica
+// ninguna y devuelve errInvitacionInvalida sin decir cuál; cada token inválido
+// cuenta como una búsqueda fallida para el bloqueo. Si a alguno ya se le cerró
+// el plazo, el global o el de uno de sus eventos, tampoco se aplica ninguna.
+func (s *servidor) actualizarAsistenciaLotePorToken(gc *gin.Context, listaAsistencia []Asistencia) ([]ResultadoAsistencia, error) {
+	porIdText := make([]Asistencia, len(listaAsistencia))
+
+	desconocidos := 0
+	for i, asistencia := range listaAsistencia {
+		porIdText[i].Asiste = asistencia.Asiste
+
+		invitacion, err := s.resolverInvitacion(gc, asistencia.Id_text, alcanceRsvp, invitacionInvitado)
+		if errors.Is(err, errInvitacionInvalida) {
+			desconocidos++
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		porIdText[i].Id_text = invitacion.Id_text
+	}
+	if desconocidos > 0 {
+		contarFallos(gc, desconocidos)
+		return nil, errInvitacionInvalida
+	}
+
+	for _, asistencia := range porIdText {
+		plazo, err := s.plazoDeRespuesta(gc.Request.Context(), asistencia.Id_text)
+		if err != nil {
+			return nil, err
+		}
+		if plazo.Cerrado {
+			return nil, plazoVencido(plazo)
+		}
+	}
+
+	resultados, err := s.invitados.ActualizarAsistenciaLote(gc.Request.Context(), porIdText)
+	for i := range resultados {
+		resultados[i].Id_text = listaAsistencia[i].Id_text
+	}
+
+	return resultados, err
+}
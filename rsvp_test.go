@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAsistenciaConPlazoDeEvento(t *testing.T) {
+	ctx := context.Background()
+	ayer := time.Now().Add(-24 * time.Hour)
+	manana := time.Now().Add(24 * time.Hour)
+
+	casos := []struct {
+		nombre     string
+		limite     time.Time
+		prorroga   *time.Time
+		ruta       string
+		estado     int
+		respondido bool
+	}{
+		{"evento abierto", manana, nil, "/asistencia/aceptar", http.StatusOK, true},
+		{"evento vencido", ayer, nil, "/asistencia/aceptar", http.StatusForbidden, false},
+		{"evento vencido al rechazar", ayer, nil, "/asistencia/rechazar", http.StatusForbidden, false},
+		{"evento vencido en el lote", ayer, nil, "/asistencia/lote", http.StatusForbidden, false},
+		{"prórroga después del evento", ayer, &manana, "/asistencia/aceptar", http.StatusOK, true},
+		{"prórroga después del evento en el lote", ayer, &manana, "/asistencia/lote", http.StatusOK, true},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			store := storePrueba(t)
+			s, router := servidorPrueba(store)
+			router.POST("/asistencia/aceptar", s.aceptarInvitacion)
+			router.POST("/asistencia/rechazar", s.rechazarInvitacion)
+			router.POST("/asistencia/lote", s.updateMultiplesInvitadosAsistencia)
+
+			ana, err := store.CrearInvitado(ctx, InvitadoCommand{Nombre: "Ana", Nombre_invitacion: "Ana"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, cmd := range []EventoCommand{{Nombre: "Civil", Limite_rsvp: &c.limite}, {Nombre: "Banquete", Orden: 1}} {
+				evento, err := store.CrearEvento(ctx, cmd)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.InvitarAEvento(ctx, evento.Id_text, ana.Id_text); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if c.prorroga != nil {
+				if _, err := store.CambiarProrroga(ctx, ana.Id_text, c.prorroga); err != nil {
+					t.Fatal(err)
+				}
+			}
+			token, err := store.TokenInvitacion(ctx, invitacionInvitado, ana.Id_text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cuerpo := `{"invitado_id":"` + token + `"}`
+			if c.ruta == "/asistencia/lote" {
+				cuerpo = `[{"id_text":"` + token + `","asiste":true}]`
+			}
+			peticion := httptest.NewRequest(http.MethodPost, c.ruta, strings.NewReader(cuerpo))
+			peticion.Header.Set("Content-Type", "application/json")
+			respuesta := httptest.NewRecorder()
+			router.ServeHTTP(respuesta, peticion)
+
+			if respuesta.Code != c.estado {
+				t.Errorf("POST %s = %v, se esperaba %v: %s", c.ruta, respuesta.Code, c.estado, respuesta.Body)
+			}
+
+			eventos, err := store.EventosDeInvitado(ctx, ana.Id_text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, e := range eventos {
+				if (e.Asiste != nil) != c.respondido {
+					t.Errorf("respuesta a %s = %v, se esperaba respondido %v", e.Evento.Nombre, e.Asiste, c.respondido)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// servidor reúne los handlers HTTP. Los stores se reciben desde fuera para
+// poder cambiar la base de datos o usar dobles en las pruebas.
+type servidor struct {
+	invitados    GuestStore
+	familias     FamilyStore
+	canciones    SongStore
+	mensajes     MessageStore
+	usuarios     UserStore
+	invitaciones InvitationStore
+	eventos      EventStore
+	menus        MenuStore
+	auditoria    AuditStore
+	firmas       *firmador
+	plantillas   *plantillas
+	limitador    RateLimitStore
+	limites      limitesPeticiones
+	plazo        plazoRsvp
+
+	// aceptarIdText permite resolver enlaces viejos por id_text, ver IDTEXTLEGADO.
+	aceptarIdText bool
+	cabeceraIP    string
+	politicaCors  politicaCors
+	// urlBase es la URL pública del backend, para el documento OpenAPI.
+	urlBase string
+}
+
+func nuevoServidor(invitados GuestStore, familias FamilyStore, canciones SongStore, mensajes MessageStore, usuarios UserStore, invitaciones InvitationStore, eventos EventStore, menus MenuStore, auditoria AuditStore) *servidor {
+	return &servidor{
+		invitados:    invitados,
+		familias:     familias,
+		canciones:    canciones,
+		mensajes:     mensajes,
+		usuarios:     usuarios,
+		invitaciones: invitaciones,
+		eventos:      eventos,
+		menus:        menus,
+		auditoria:    auditoria,
+	}
+}
+
+func (s *servidor) rutas() *gin.Engine {
+	router := gin.Default()
+	router.TrustedPlatform = s.cabeceraIP
+	router.Use(s.cors, s.protegerSesion, s.registrarAutor)
+
+	// Las consultas por id_text, las listas, la tabla de respuestas, las
+	// exportaciones y la gestión requieren sesión. Las rutas que abren los
+	// invitados son públicas y reciben el token de su invitación.
+	gestion := s.requiereRol(rolesGestion...)
+	lectura := s.requiereRol(rolesLectura...)
+	soloPareja := s.requiereRol(rolPareja)
+
+	consulta := s.limitar("consulta", s.limites.PorToken)
+	respuesta := s.limitar("respuesta", s.limites.PorToken)
+	escritura := s.limitar("escritura", s.limites.Escritura)
+
+	router.POST("/admin/login", s.iniciarSesion)
+	router.POST("/admin/logout", s.cerrarSesion)
+	router.GET("/admin/yo", lectura, s.getUsuarioActual)
+	router.GET("/admin/usuarios", soloPareja, s.getUsuarios)
+	router.POST("/admin/usuarios", soloPareja, s.crearUsuario)
+	router.POST("/admin/tokens", lectura, s.crearTokenApiPropio)
+	router.DELETE("/admin/tokens/:id", lectura, s.revocarTokenApi)
+	router.GET("/admin/historial", soloPareja, s.getHistorialAdmin)
+
+	router.GET("/familias", gestion, s.getFamilias)
+	// Un invitado o una familia se consulta siempre en la misma URL: la gestión
+	// con su sesión y los invitados con el token del enlace, en JSON o HTML
+	// según lo que pidan. Las rutas viejas son alias con la vista HTML fija.
+	router.GET("/familias/:id", s.gestionOInvitado(consulta), s.requiereAlcance(""), s.getFamilia)
+
+	router.POST("/familias", gestion, s.crearFamilia)
+	router.PUT("/familias/:id", gestion, s.reemplazarFamilia)
+	router.DELETE("/familias/:id", gestion, s.eliminarFamilia)
+	router.PUT("/familias/:id/invitados/:invitadoId", gestion, s.agregarInvitadoAFamilia)
+	router.DELETE("/familias/:id/invitados/:invitadoId", gestion, s.quitarInvitadoDeFamilia)
+	router.GET("/familias/:id/invitacion", gestion, s.getInvitacion(invitacionFamilia))
+	router.POST("/familias/:id/invitacion", gestion, s.rotarInvitacion(invitacionFamilia))
+	router.DELETE("/familias/:id/invitacion", gestion, s.revocarInvitacion(invitacionFamilia))
+	router.POST("/familias/:id/enlace", gestion, s.crearEnlaceFirmado(invitacionFamilia))
+	router.PUT("/familias/:id/cupo", gestion, s.cambiarCupoFamilia)
+	router.GET("/familias/:id/historial", gestion, s.getHistorialFamilia)
+
+	router.GET("/familias/presentacion/:id", fijarVista(vistaPresentacion), consulta, s.requiereAlcance(""), s.getFamilia)
+
+	router.GET("/invitados", gestion, s.getInvitados)
+	router.GET("/invitados/:id", s.gestionOInvitado(consulta), s.requiereAlcance(""), s.getInvitado)
+	router.GET("/invitados/byfamilia/:id", gestion, s.getInvitadoByFamiliaId)
+
+	router.POST("/invitados", gestion, s.crearInvitado)
+	router.POST("/invitados/importar", gestion, s.importarInvitados)
+	router.PUT("/invitados/:id", gestion, s.reemplazarInvitado)
+	router.PATCH("/invitados/:id", gestion, s.modificarInvitado)
+	router.DELETE("/invitados/:id", gestion, s.eliminarInvitado)
+	router.GET("/invitados/:id/invitacion", gestion, s.getInvitacion(invitacionInvitado))
+	router.POST("/invitados/:id/invitacion", gestion, s.rotarInvitacion(invitacionInvitado))
+	router.DELETE("/invitados/:id/invitacion", gestion, s.revocarInvitacion(invitacionInvitado))
+	router.POST("/invitados/:id/enlace", gestion, s.crearEnlaceFirmado(invitacionInvitado))
+
+	router.GET("/invitados/:id/eventos", gestion, s.getEventosDeInvitado)
+	router.PUT("/invitados/:id/cupo", gestion, s.cambiarCupoInvitado)
+	router.PUT("/invitados/:id/mesa", gestion, s.cambiarMesa)
+	router.PUT("/invitados/:id/prorroga", gestion, s.cambiarProrroga)
+	router.GET("/invitados/:id/historial", gestion, s.getHistorialInvitado)
+	router.GET("/invitados/:id/menu", gestion, s.getMenuInvitado)
+	router.PUT("/invitados/:id/menu", gestion, s.cambiarMenuInvitado)
+
+	router.GET("/invitaciones", gestion, s.getInvitaciones)
+
+	// Cada evento tiene su lista de invitados. Los invitados responden a cada
+	// uno con el token de su invitación, como en /asistencia.
+	router.GET("/eventos", lectura, s.getEventos)
+	router.GET("/eventos/:id", lectura, s.getEvento)
+	router.POST("/eventos", gestion, s.crearEvento)
+	router.PUT("/eventos/:id", gestion, s.reemplazarEvento)
+	router.DELETE("/eventos/:id", gestion, s.eliminarEvento)
+	router.GET("/eventos/:id/invitados", lectura, s.getInvitadosDeEvento)
+	router.PUT("/eventos/:id/invitados/:invitadoId", gestion, s.invitarAEvento)
+	router.DELETE("/eventos/:id/invitados/:invitadoId", gestion, s.quitarDeEvento)
+	router.GET("/eventos/:id/historial", gestion, s.getHistorialEvento)
+	router.POST("/eventos/:id/asistencia/aceptar", respuesta, s.requiereAlcance(alcanceRsvp), s.aceptarEvento)
+	router.POST("/eventos/:id/asistencia/rechazar", respuesta, s.requiereAlcance(alcanceRsvp), s.rechazarEvento)
+
+	// Los platillos del menú son públicos: los invitados los ven al aceptar.
+	router.GET("/platillos", consulta, s.getPlatillos)
+	router.GET("/platillos/:id", consulta, s.getPlatillo)
+	router.POST("/platillos", gestion, s.crearPlatillo)
+	router.PUT("/platillos/:id", gestion, s.reemplazarPlatillo)
+	router.DELETE("/platillos/:id", gestion, s.eliminarPlatillo)
+	router.GET("/platillos/:id/historial", gestion, s.getHistorialPlatillo)
+	router.GET("/catering", lectura, s.getReporteCatering)
+
+	router.GET("/invitados/presentacion/:id", fijarVista(vistaPresentacion), consulta, s.requiereAlcance(""), s.getInvitado)
+
+	router.GET("/rsvp", lectura, s.getTablaRsvp)
+	router.GET("/invitados/tabla-rsvp", fijarVista(vistaTabla), lectura, s.getTablaRsvp)
+
+	router.GET("/export/rsvp", lectura, s.exportarRsvp)
+
+	router.GET("/verificarInvitado/:id", fijarVista(vistaFila), consulta, s.requiereAlcance(alcanceRsvp), s.getInvitado)
+
+	router.GET("/verificarFamilia/:id", fijarVista(vistaFila), consulta, s.requiereAlcance(alcanceRsvp), s.getFamilia)
+
+	router.POST("/asistencia/lote", respuesta, s.updateMultiplesInvitadosAsistencia)
+	router.POST("/asistencia/rechazar", fijarVista(vistaFila), respuesta, s.requiereAlcance(alcanceRsvp), s.rechazarInvitacion)
+	router.POST("/asistencia/aceptar", fijarVista(vistaFila), respuesta, s.requiereAlcance(alcanceRsvp), s.aceptarInvitacion)
+	router.POST("/acompanantes", respuesta, s.requiereAlcance(alcanceRsvp), s.nombrarAcompanantes)
+	router.POST("/menu", respuesta, s.requiereAlcance(alcanceRsvp), s.elegirMenu)
+	router.POST("/cancion", fijarVista(vistaFormulario), escritura, s.requiereAlcance(alcanceCancion), s.agregarCancion)
+	router.POST("/mensaje", fijarVista(vistaFormulario), escritura, s.requiereAlcance(alcanceMensaje), s.agregarMensaje)
+
+	// /api/v1 solo habla JSON, con DTOs en snake_case, y publica su documento
+	// OpenAPI para generar clientes.
+	v1 := router.Group(prefijoApiV1, marcarApiV1)
+	rutasV1 := s.rutasApiV1()
+	for _, r := range rutasV1 {
+		v1.Handle(r.Metodo, r.Ruta, r.Handlers...)
+	}
+	documento := documentoOpenApi(rutasV1, s.urlBase)
+	v1.GET("/openapi.json", func(gc *gin.Context) {
+		gc.IndentedJSON(http.StatusOK, documento)
+	})
+
+	return router
+}
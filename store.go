@@ -0,0 +1,219 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var errInvitadoNoEncontrado = errors.New("invitado no encontrado")
+var errInvitadoEsPrincipal = errors.New("el invitado es el miembro principal de una familia")
+var errFamiliaNoEncontrada = errors.New("familia no encontrada")
+var errMiembroPrincipalInvalido = errors.New("el miembro principal no existe")
+var errMiembroPrincipalDeOtraFamilia = errors.New("el miembro principal ya lo es de otra familia")
+var errFamiliaConMiembros = errors.New("la familia todavía tiene invitados")
+var errAsistenciaLoteIncompleta = errors.New("la lista de asistencia tiene invitados desconocidos")
+var errUsuarioNoEncontrado = errors.New("usuario no encontrado")
+var errUsuarioExistente = errors.New("ya existe un usuario con ese nombre")
+var errCredencialInvalida = errors.New("sesión o token inválido")
+var errTokenApiNoEncontrado = errors.New("token de API no encontrado")
+var errInvitacionInvalida = errors.New("la invitación no existe, fue revocada o expiró")
+var errEventoNoEncontrado = errors.New("evento no encontrado")
+var errNoInvitadoAEvento = errors.New("el invitado no está invitado al evento")
+var errInvitadoEsAcompanante = errors.New("el invitado es acompañante de otro invitado")
+var errAnfitrionNoAsiste = errors.New("el invitado no confirmó su asistencia")
+var errCupoAcompanantesExcedido = errors.New("el invitado no tiene cupo para tantos acompañantes")
+var errPlatilloNoEncontrado = errors.New("platillo no encontrado")
+var errMenuSinAsistencia = errors.New("el invitado no confirmó su asistencia")
+
+// GuestStore guarda los invitados y sus respuestas a la invitación.
+type GuestStore interface {
+	ListarInvitados(ctx context.Context) ([]InvitadoResp, error)
+	// InvitadoPorId devuelve errInvitadoNoEncontrado si no existe el id_text.
+	InvitadoPorId(ctx context.Context, idText string) (InvitadoResp, error)
+	InvitadosPorFamilia(ctx context.Context, idTextFamilia string) ([]InvitadoResp, error)
+	InvitadosConFamilia(ctx context.Context) ([]InvitadoFamilia, error)
+
+	CrearInvitado(ctx context.Context, cmd InvitadoCommand) (InvitadoResp, error)
+	// ReemplazarInvitado sobrescribe los nombres y la asistencia; un Asiste
+	// nil deja al invitado sin respuesta. La asistencia solo pasa a sus eventos
+	// y acompañantes si cambia.
+	ReemplazarInvitado(ctx context.Context, idText string, cmd InvitadoCommand) (InvitadoResp, error)
+	// ModificarInvitado es como ReemplazarInvitado, salvo que un Asiste nil
+	// conserva las respuestas del invitado y las de sus eventos.
+	ModificarInvitado(ctx context.Context, idText string, cmd InvitadoCommand) (InvitadoResp, error)
+	// EliminarInvitado borra al invitado, a sus acompañantes y lo que cuelga
+	// de ellos: respuestas a eventos y tokens de invitación.
+	EliminarInvitado(ctx context.Context, idText string) error
+
+	// RegistrarAsistencia devuelve asistenciaActualizada, asistenciaSinCambios
+	// o asistenciaDesconocida. Si el invitado tiene eventos, la respuesta se
+	// aplica a todos.
+	RegistrarAsistencia(ctx context.Context, idText string, asiste bool) (string, error)
+	// ActualizarAsistenciaLote aplica toda la lista o nada. Si algún invitado
+	// no existe devuelve los resultados junto a errAsistenciaLoteIncompleta.
+	ActualizarAsistenciaLote(ctx context.Context, listaAsistencia []Asistencia) ([]ResultadoAsistencia, error)
+
+	// Acompanantes devuelve cuántos acompañantes puede nombrar el invitado y
+	// los que ya nombró.
+	Acompanantes(ctx context.Context, idTextAnfitrion string) (Acompanantes, error)
+	// NombrarAcompanantes reemplaza los acompañantes del invitado por los de
+	// nombres. Devuelve errAnfitrionNoAsiste si no confirmó su asistencia,
+	// errInvitadoEsAcompanante si él mismo es acompañante y
+	// errCupoAcompanantesExcedido, con el cupo, si no le alcanza.
+	NombrarAcompanantes(ctx context.Context, idTextAnfitrion string, nombres []string) (Acompanantes, error)
+	// CambiarCupoInvitado fija cuántos acompañantes puede nombrar por su cuenta.
+	// Bajarlo no borra a los que ya nombró.
+	CambiarCupoInvitado(ctx context.Context, idText string, cupo int) (InvitadoResp, error)
+	// CambiarProrroga fija hasta cuándo puede responder el invitado aunque
+	// haya pasado la fecha límite; nil se la quita.
+	CambiarProrroga(ctx context.Context, idText string, hasta *time.Time) (InvitadoResp, error)
+	// CambiarMesa asigna la mesa del invitado; vacía lo deja sin mesa.
+	CambiarMesa(ctx context.Context, idText string, mesa string) (InvitadoResp, error)
+
+	// AplicarImportacion aplica un plan de importación completo o nada. Los
+	// invitados eliminados en el plan solo se borran si eliminar es verdadero,
+	// como en EliminarInvitado.
+	AplicarImportacion(ctx context.Context, plan planImportacion, eliminar bool) error
+}
+
+// FamilyStore guarda las familias y qué invitados pertenecen a cada una.
+type FamilyStore interface {
+	ListarFamilias(ctx context.Context) ([]FamiliasResp, error)
+	// FamiliaPorId devuelve errFamiliaNoEncontrada si no existe el id_text.
+	FamiliaPorId(ctx context.Context, idText string) (FamiliasResp, error)
+
+	CrearFamilia(ctx context.Context, cmd FamiliasCommand) (FamiliasResp, error)
+	ReemplazarFamilia(ctx context.Context, idText string, cmd FamiliasCommand) (FamiliasResp, error)
+	EliminarFamilia(ctx context.Context, idText string, cascada bool) error
+	// MoverInvitado asigna el invitado a la familia. Con idTextFamilia vacío el
+	// invitado queda sin familia.
+	MoverInvitado(ctx context.Context, idTextInvitado string, idTextFamilia string) (InvitadoResp, error)
+	// CambiarCupoFamilia fija cuántos acompañantes comparten sus miembros.
+	CambiarCupoFamilia(ctx context.Context, idText string, cupo int) (FamiliasResp, error)
+}
+
+// EventStore guarda los eventos de la boda, quiénes están invitados a cada uno
+// y sus respuestas. Para un invitado con eventos, Invitados.asiste pasa a ser
+// un resumen: verdadero si va a alguno, falso si rechazó todos y nulo
+// mientras le falte responder alguno.
+type EventStore interface {
+	ListarEventos(ctx context.Context) ([]EventoResp, error)
+	// EventoPorId devuelve errEventoNoEncontrado si no existe el id_text.
+	EventoPorId(ctx context.Context, idText string) (EventoResp, error)
+
+	CrearEvento(ctx context.Context, cmd EventoCommand) (EventoResp, error)
+	ReemplazarEvento(ctx context.Context, idText string, cmd EventoCommand) (EventoResp, error)
+	// EliminarEvento borra también las invitaciones y respuestas al evento.
+	EliminarEvento(ctx context.Context, idText string) error
+
+	// InvitadosDeEvento devuelve los invitados al evento con Asiste igual a su
+	// respuesta a ese evento.
+	InvitadosDeEvento(ctx context.Context, idTextEvento string) ([]InvitadoResp, error)
+	// InvitarAEvento no hace nada si el invitado ya estaba invitado.
+	InvitarAEvento(ctx context.Context, idTextEvento string, idTextInvitado string) error
+	// QuitarDeEvento devuelve errNoInvitadoAEvento si no estaba invitado.
+	QuitarDeEvento(ctx context.Context, idTextEvento string, idTextInvitado string) error
+
+	// EventosDeInvitado devuelve, en orden, los eventos a los que está invitado
+	// con su respuesta a cada uno.
+	EventosDeInvitado(ctx context.Context, idTextInvitado string) ([]AsistenciaEvento, error)
+	// RegistrarAsistenciaEvento devuelve asistenciaActualizada o
+	// asistenciaSinCambios, o errNoInvitadoAEvento si no está invitado.
+	RegistrarAsistenciaEvento(ctx context.Context, idTextInvitado string, idTextEvento string, asiste bool) (string, error)
+	TotalesPorEvento(ctx context.Context) ([]totalesEvento, error)
+}
+
+// MenuStore guarda las opciones del menú y lo que eligió cada invitado que
+// aceptó: su platillo, sus restricciones y sus alergias.
+type MenuStore interface {
+	ListarPlatillos(ctx context.Context) ([]PlatilloResp, error)
+	// PlatilloPorId devuelve errPlatilloNoEncontrado si no existe el id_text.
+	PlatilloPorId(ctx context.Context, idText string) (PlatilloResp, error)
+
+	CrearPlatillo(ctx context.Context, cmd PlatilloCommand) (PlatilloResp, error)
+	ReemplazarPlatillo(ctx context.Context, idText string, cmd PlatilloCommand) (PlatilloResp, error)
+	// EliminarPlatillo deja sin platillo a quienes lo habían elegido.
+	EliminarPlatillo(ctx context.Context, idText string) error
+
+	MenuDeInvitado(ctx context.Context, idTextInvitado string) (EleccionMenu, error)
+	// ElegirMenu reemplaza la elección del invitado. Devuelve
+	// errMenuSinAsistencia si no aceptó y errPlatilloNoEncontrado si el
+	// platillo no existe; un platillo vacío lo deja sin elegir.
+	ElegirMenu(ctx context.Context, idTextInvitado string, eleccion EleccionMenu) (EleccionMenu, error)
+	// MenusConfirmados devuelve la elección de cada invitado que aceptó algún
+	// evento en el que se sirve la comida, o que aceptó sin tener eventos, con
+	// su mesa o, si es acompañante sin mesa, la de su anfitrión. Los
+	// acompañantes cuentan según los eventos de su anfitrión.
+	MenusConfirmados(ctx context.Context) ([]menuConfirmado, error)
+}
+
+// AuditStore lee el historial de cambios. Las entradas las escribe el store de
+// cada cambio en su misma transacción; nunca se modifican ni se borran.
+type AuditStore interface {
+	// HistorialInvitado devuelve los cambios al invitado por fecha.
+	HistorialInvitado(ctx context.Context, idText string) ([]EntradaAuditoria, error)
+	// HistorialFamilia devuelve los cambios a la familia y a sus miembros
+	// mientras lo eran, por fecha.
+	HistorialFamilia(ctx context.Context, idText string) ([]EntradaAuditoria, error)
+	// HistorialRecurso devuelve los cambios al evento o platillo idText por
+	// fecha.
+	HistorialRecurso(ctx context.Context, recurso string, idText string) ([]EntradaAuditoria, error)
+	// HistorialAdmin devuelve las altas de usuarios y los tokens de API
+	// creados y revocados, por fecha.
+	HistorialAdmin(ctx context.Context) ([]EntradaAuditoria, error)
+}
+
+// SongStore guarda las canciones que proponen los invitados.
+type SongStore interface {
+	AgregarCancion(ctx context.Context, idInvitado string, nombreCancion string) error
+}
+
+// MessageStore guarda los mensajes que dejan los invitados.
+type MessageStore interface {
+	AgregarMensaje(ctx context.Context, idInvitado string, contenido string) error
+}
+
+// UserStore guarda los usuarios de administración, sus sesiones y sus tokens
+// de API. Las claves llegan ya procesadas con bcrypt y los tokens como hash,
+// así que el store nunca ve un secreto en claro.
+type UserStore interface {
+	// CrearUsuario devuelve errUsuarioExistente si el nombre ya está en uso.
+	CrearUsuario(ctx context.Context, nombre string, hashClave string, rol string) (Usuario, error)
+	ListarUsuarios(ctx context.Context) ([]Usuario, error)
+	// UsuarioPorNombre devuelve el usuario con el hash de su clave, o
+	// errUsuarioNoEncontrado.
+	UsuarioPorNombre(ctx context.Context, nombre string) (Usuario, string, error)
+
+	CrearSesion(ctx context.Context, idUsuario int64, hashToken string, expira time.Time) error
+	// UsuarioPorSesion devuelve errCredencialInvalida si la sesión no existe o
+	// ya expiró.
+	UsuarioPorSesion(ctx context.Context, hashToken string) (Usuario, error)
+	EliminarSesion(ctx context.Context, hashToken string) error
+
+	CrearTokenApi(ctx context.Context, idUsuario int64, nombre string, hashToken string) (int64, error)
+	// UsuarioPorTokenApi devuelve errCredencialInvalida si el token no existe o
+	// fue revocado.
+	UsuarioPorTokenApi(ctx context.Context, hashToken string) (Usuario, error)
+	// RevocarTokenApi solo revoca tokens del propio usuario; si no hay ninguno
+	// con ese id devuelve errTokenApiNoEncontrado.
+	RevocarTokenApi(ctx context.Context, idUsuario int64, idToken int64) error
+}
+
+// InvitationStore guarda los tokens de los enlaces de invitación. Cada
+// invitado o familia tiene como mucho un token vigente.
+type InvitationStore interface {
+	// ResolverInvitacion devuelve errInvitacionInvalida si el token no existe,
+	// fue revocado o expiró.
+	ResolverInvitacion(ctx context.Context, token string) (Invitacion, error)
+	// TokenInvitacion devuelve el token vigente del invitado o la familia y
+	// emite uno si nunca tuvo. Si el último fue revocado o expiró devuelve
+	// errInvitacionInvalida: solo RotarInvitacion vuelve a emitir. Ninguno de
+	// los dos emite para un acompañante: devuelven errInvitadoEsAcompanante.
+	TokenInvitacion(ctx context.Context, tipo string, idText string) (string, error)
+	// RotarInvitacion revoca el token vigente y emite uno nuevo. Un expira cero
+	// significa que no expira.
+	RotarInvitacion(ctx context.Context, tipo string, idText string, expira time.Time) (Invitacion, error)
+	RevocarInvitacion(ctx context.Context, tipo string, idText string) error
+	ListarInvitaciones(ctx context.Context) ([]Invitacion, error)
+}
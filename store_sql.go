@@ -0,0 +1,2620 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqlStore implementa todos los stores sobre la base de datos de la boda. Las
+// consultas se escriben para que funcionen igual en MySQL y en SQLite.
+type sqlStore struct {
+	db *sql.DB
+}
+
+func nuevoSqlStore(db *sql.DB) *sqlStore {
+	return &sqlStore{db: db}
+}
+
+// consultaInvitados trae las columnas de InvitadoResp, con el id_text del
+// anfitrión de los acompañantes.
+const consultaInvitados = `SELECT i.id, i.id_text, i.nombre, i.nombre_invitacion, i.asiste, i.acompanantes, a.id_text, i.mesa, i.prorroga_rsvp
+	FROM Invitados i LEFT JOIN Invitados a ON a.id = i.id_anfitrion`
+
+func escanearInvitado(fila interface{ Scan(...any) error }) (InvitadoResp, error) {
+	var invitado InvitadoResp
+	var anfitrion sql.NullString
+	var prorroga sql.NullInt64
+	err := fila.Scan(
+		&invitado.Id,
+		&invitado.Id_text,
+		&invitado.Nombre,
+		&invitado.Nombre_invitacion,
+		&invitado.Asiste,
+		&invitado.Cupo_acompanantes,
+		&anfitrion,
+		&invitado.Mesa,
+		&prorroga)
+	if anfitrion.Valid {
+		invitado.Anfitrion = &anfitrion.String
+	}
+	invitado.Prorroga = fechaUnix(prorroga)
+	return invitado, err
+}
+
+func (s *sqlStore) ListarInvitados(ctx context.Context) ([]InvitadoResp, error) {
+
+	var invitados []InvitadoResp
+
+	invResp, err := s.db.QueryContext(ctx, consultaInvitados)
+
+	if err != nil {
+		return nil, fmt.Errorf("ListarInvitados %s", err)
+	}
+
+	defer invResp.Close()
+
+	for invResp.Next() {
+		invitado, err := escanearInvitado(invResp)
+		if err != nil {
+			return nil, fmt.Errorf("ListarInvitados %s", err)
+		}
+		invitados = append(invitados, invitado)
+	}
+
+	return invitados, nil
+}
+
+func (s *sqlStore) InvitadoPorId(ctx context.Context, idText string) (InvitadoResp, error) {
+	invitado, err := escanearInvitado(s.db.QueryRowContext(ctx, consultaInvitados+" WHERE i.id_text = ?", idText))
+	if errors.Is(err, sql.ErrNoRows) {
+		return invitado, errInvitadoNoEncontrado
+	}
+	if err != nil {
+		return invitado, fmt.Errorf("InvitadoPorId %s", err)
+	}
+
+	return invitado, nil
+}
+
+func (s *sqlStore) InvitadosPorFamilia(ctx context.Context, idTextFamilia string) ([]InvitadoResp, error) {
+
+	var invitados []InvitadoResp
+
+	invResp, err := s.db.QueryContext(ctx, consultaInvitados+" INNER JOIN Familias f ON i.id_familia = f.id WHERE f.id_text = ?", idTextFamilia)
+
+	if err != nil {
+		return nil, fmt.Errorf("InvitadosPorFamilia %s", err)
+	}
+
+	defer invResp.Close()
+
+	for invResp.Next() {
+		invitado, err := escanearInvitado(invResp)
+		if err != nil {
+			return nil, fmt.Errorf("InvitadosPorFamilia %s", err)
+		}
+		invitados = append(invitados, invitado)
+	}
+
+	return invitados, nil
+}
+
+func (s *sqlStore) InvitadosConFamilia(ctx context.Context) ([]InvitadoFamilia, error) {
+
+	var invitadosFamilias []InvitadoFamilia
+
+	invResp, err := s.db.QueryContext(ctx, "SELECT inv.id, inv.id_text, inv.nombre, inv.nombre_invitacion, inv.asiste, inv.id_anfitrion IS NOT NULL, fam.id_text, fam.nombre FROM Invitados inv LEFT JOIN Familias fam on inv.id_familia = fam.id")
+
+	if err != nil {
+		return nil, fmt.Errorf("InvitadosConFamilia %s", err)
+	}
+
+	defer invResp.Close()
+
+	for invResp.Next() {
+		var invitadoFam InvitadoFamilia
+		if err := invResp.Scan(
+			&invitadoFam.Id,
+			&invitadoFam.Id_text,
+			&invitadoFam.Nombre,
+			&invitadoFam.Nombre_invitacion,
+			&invitadoFam.Asiste,
+			&invitadoFam.Acompanante,
+			&invitadoFam.Id_text_familia,
+			&invitadoFam.Nombre_familia); err != nil {
+			return nil, fmt.Errorf("InvitadosConFamilia %s", err)
+		}
+		invitadosFamilias = append(invitadosFamilias, invitadoFam)
+	}
+
+	return invitadosFamilias, nil
+}
+
+// ejecutor es lo que comparten *sql.DB y *sql.Tx, para reutilizar consultas
+// dentro y fuera de una transacción.
+type ejecutor interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// generarIdTextDB genera un id_text que no exista todavía en la tabla indicada.
+func generarIdTextDB(ctx context.Context, ej ejecutor, tabla string) (string, error) {
+	for intento := 0; intento < 5; intento++ {
+		idText, err := generarIdText()
+		if err != nil {
+			return "", err
+		}
+		var existe bool
+		if err := ej.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM "+tabla+" WHERE id_text = ?)", idText).Scan(&existe); err != nil {
+			return "", fmt.Errorf("generarIdTextDB %s", err)
+		}
+		if !existe {
+			return idText, nil
+		}
+	}
+	return "", errors.New("generarIdTextDB no se pudo generar un id_text único")
+}
+
+func (s *sqlStore) CrearInvitado(ctx context.Context, cmd InvitadoCommand) (InvitadoResp, error) {
+	idText, err := generarIdTextDB(ctx, s.db, "Invitados")
+	if err != nil {
+		return InvitadoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return InvitadoResp{}, fmt.Errorf("CrearInvitado %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Invitados (id_text, nombre, nombre_invitacion, asiste) VALUES (?, ?, ?, ?)",
+		idText, cmd.Nombre, cmd.Nombre_invitacion, cmd.Asiste); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CrearInvitado %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCrearInvitado, Invitado: idText, Nuevo: cmd}); err != nil {
+		return InvitadoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CrearInvitado %s", err)
+	}
+
+	return s.InvitadoPorId(ctx, idText)
+}
+
+func (s *sqlStore) ReemplazarInvitado(ctx context.Context, idText string, cmd InvitadoCommand) (InvitadoResp, error) {
+	return s.guardarInvitado(ctx, idText, cmd, false)
+}
+
+func (s *sqlStore) ModificarInvitado(ctx context.Context, idText string, cmd InvitadoCommand) (InvitadoResp, error) {
+	return s.guardarInvitado(ctx, idText, cmd, cmd.Asiste == nil)
+}
+
+// guardarInvitado escribe los nombres y, salvo con conservarAsiste, la
+// asistencia. Con eventos, la general es el resumen que calcula
+// sincronizarAsistenciaTx, así que solo una respuesta distinta de ese resumen
+// pasa a los eventos y a los acompañantes; repetirla no borra las respuestas
+// que el invitado dio a cada evento.
+func (s *sqlStore) guardarInvitado(ctx context.Context, idText string, cmd InvitadoCommand, conservarAsiste bool) (InvitadoResp, error) {
+	invitado, err := s.InvitadoPorId(ctx, idText)
+	if err != nil {
+		return InvitadoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return InvitadoResp{}, fmt.Errorf("guardarInvitado %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET nombre = ?, nombre_invitacion = ? WHERE id = ?",
+		cmd.Nombre, cmd.Nombre_invitacion, invitado.Id); err != nil {
+		return InvitadoResp{}, fmt.Errorf("guardarInvitado %s", err)
+	}
+
+	nuevo := cmd
+	asiste := sql.NullBool{Valid: cmd.Asiste != nil}
+	if cmd.Asiste != nil {
+		asiste.Bool = *cmd.Asiste
+	}
+	switch {
+	case conservarAsiste:
+		nuevo.Asiste = asisteAuditada(invitado.Asiste)
+	case asiste != invitado.Asiste:
+		if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET asiste = ? WHERE id = ?", asiste, invitado.Id); err != nil {
+			return InvitadoResp{}, fmt.Errorf("guardarInvitado %s", err)
+		}
+		if _, err := responderEventosTx(ctx, tx, invitado.Id, idText, asiste); err != nil {
+			return InvitadoResp{}, err
+		}
+		if err := reflejarEnAcompanantesTx(ctx, tx, invitado.Id, asiste); err != nil {
+			return InvitadoResp{}, err
+		}
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEditarInvitado, Invitado: idText, Anterior: invitadoAuditado(invitado), Nuevo: nuevo}); err != nil {
+		return InvitadoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return InvitadoResp{}, fmt.Errorf("guardarInvitado %s", err)
+	}
+
+	return s.InvitadoPorId(ctx, idText)
+}
+
+func (s *sqlStore) EliminarInvitado(ctx context.Context, idText string) error {
+	invitado, err := s.InvitadoPorId(ctx, idText)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("EliminarInvitado %s", err)
+	}
+	defer tx.Rollback()
+
+	var esPrincipal bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Familias WHERE miembro_principal = ?)", invitado.Id).Scan(&esPrincipal); err != nil {
+		return fmt.Errorf("EliminarInvitado %s", err)
+	}
+	if esPrincipal {
+		return errInvitadoEsPrincipal
+	}
+
+	if err := eliminarInvitadosTx(ctx, tx, "i.id = ?", invitado.Id); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EliminarInvitado %s", err)
+	}
+
+	return nil
+}
+
+// reflejarEnAcompanantesTx copia la asistencia del anfitrión a sus
+// acompañantes, que no responden por su cuenta.
+func reflejarEnAcompanantesTx(ctx context.Context, ej ejecutor, idAnfitrion int64, asiste sql.NullBool) error {
+	if _, err := ej.ExecContext(ctx, "UPDATE Invitados SET asiste = ? WHERE id_anfitrion = ?", asiste, idAnfitrion); err != nil {
+		return fmt.Errorf("reflejarEnAcompanantesTx %s", err)
+	}
+	return nil
+}
+
+// actualizarAsistenciaTx cambia la asistencia de un invitado dentro de tx y
+// devuelve si se actualizó, si no hubo cambios o si el invitado no existe. Si
+// el invitado tiene eventos la respuesta vale para todos ellos.
+func actualizarAsistenciaTx(ctx context.Context, tx *sql.Tx, idText string, asiste bool) (string, error) {
+	var id int64
+	var actual sql.NullBool
+
+	err := tx.QueryRowContext(ctx, "SELECT id, asiste FROM Invitados WHERE id_text = ?", idText).Scan(&id, &actual)
+	if errors.Is(err, sql.ErrNoRows) {
+		return asistenciaDesconocida, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("actualizarAsistenciaTx %s", err)
+	}
+
+	nueva := sql.NullBool{Bool: asiste, Valid: true}
+	eventos, err := responderEventosTx(ctx, tx, id, idText, nueva)
+	if err != nil {
+		return "", err
+	}
+	if actual == nueva && eventos == 0 {
+		return asistenciaSinCambios, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET asiste = ? WHERE id = ?", nueva, id); err != nil {
+		return "", fmt.Errorf("actualizarAsistenciaTx %s", err)
+	}
+	if err := reflejarEnAcompanantesTx(ctx, tx, id, nueva); err != nil {
+		return "", err
+	}
+	if actual != nueva {
+		if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionAsistencia, Invitado: idText, Anterior: asisteAuditada(actual), Nuevo: asiste}); err != nil {
+			return "", err
+		}
+	}
+
+	return asistenciaActualizada, nil
+}
+
+// responderEventosTx pone asiste en todas las respuestas del invitado a sus
+// eventos, para que no contradigan a la general que resume
+// sincronizarAsistenciaTx. Devuelve cuántas cambiaron.
+func responderEventosTx(ctx context.Context, ej ejecutor, idInvitado int64, idText string, asiste sql.NullBool) (int, error) {
+	filas, err := ej.QueryContext(ctx, `SELECT e.id, e.id_text, ie.asiste FROM InvitacionesEvento ie
+		INNER JOIN Eventos e ON e.id = ie.id_evento WHERE ie.id_invitado = ? ORDER BY e.orden, e.id`, idInvitado)
+	if err != nil {
+		return 0, fmt.Errorf("responderEventosTx %s", err)
+	}
+	type respuestaEvento struct {
+		id     int64
+		idText string
+		actual sql.NullBool
+	}
+	var cambiadas []respuestaEvento
+	for filas.Next() {
+		var r respuestaEvento
+		if err := filas.Scan(&r.id, &r.idText, &r.actual); err != nil {
+			filas.Close()
+			return 0, fmt.Errorf("responderEventosTx %s", err)
+		}
+		if r.actual != asiste {
+			cambiadas = append(cambiadas, r)
+		}
+	}
+	filas.Close()
+	if err := filas.Err(); err != nil {
+		return 0, fmt.Errorf("responderEventosTx %s", err)
+	}
+
+	for _, r := range cambiadas {
+		if _, err := ej.ExecContext(ctx, "UPDATE InvitacionesEvento SET asiste = ? WHERE id_evento = ? AND id_invitado = ?", asiste, r.id, idInvitado); err != nil {
+			return 0, fmt.Errorf("responderEventosTx %s", err)
+		}
+		if err := registrarCambioTx(ctx, ej, cambioAuditoria{Accion: accionAsistenciaEvento, Invitado: idText,
+			Anterior: AsistenciaAuditada{Evento: r.idText, Asiste: asisteAuditada(r.actual)},
+			Nuevo:    AsistenciaAuditada{Evento: r.idText, Asiste: asisteAuditada(asiste)}}); err != nil {
+			return 0, err
+		}
+	}
+	return len(cambiadas), nil
+}
+
+func (s *sqlStore) RegistrarAsistencia(ctx context.Context, idText string, asiste bool) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("RegistrarAsistencia %s", err)
+	}
+	defer tx.Rollback()
+
+	resultado, err := actualizarAsistenciaTx(ctx, tx, idText, asiste)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("RegistrarAsistencia %s", err)
+	}
+
+	return resultado, nil
+}
+
+func (s *sqlStore) ActualizarAsistenciaLote(ctx context.Context, listaAsistencia []Asistencia) ([]ResultadoAsistencia, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("ActualizarAsistenciaLote %s", err)
+	}
+	defer tx.Rollback()
+
+	resultados := make([]ResultadoAsistencia, 0, len(listaAsistencia))
+	incompleta := false
+
+	for _, asistencia := range listaAsistencia {
+		resultado, err := actualizarAsistenciaTx(ctx, tx, asistencia.Id_text, asistencia.Asiste)
+		if err != nil {
+			return nil, err
+		}
+		if resultado == asistenciaDesconocida {
+			incompleta = true
+		}
+		resultados = append(resultados, ResultadoAsistencia{Id_text: asistencia.Id_text, Resultado: resultado})
+	}
+
+	if incompleta {
+		return resultados, errAsistenciaLoteIncompleta
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("ActualizarAsistenciaLote %s", err)
+	}
+
+	return resultados, nil
+}
+
+func (s *sqlStore) ListarFamilias(ctx context.Context) ([]FamiliasResp, error) {
+
+	var familias []FamiliasResp
+
+	famResp, err := s.db.QueryContext(ctx, "SELECT id, id_text, nombre, miembro_principal, nombre_invitacion, acompanantes FROM Familias")
+
+	if err != nil {
+		return nil, fmt.Errorf("ListarFamilias %s", err)
+	}
+
+	defer famResp.Close()
+
+	for famResp.Next() {
+		var familia FamiliasResp
+		if err := famResp.Scan(
+			&familia.Id,
+			&familia.Id_text,
+			&familia.Nombre,
+			&familia.Miembro_principal,
+			&familia.Nombre_invitacion,
+			&familia.Cupo_acompanantes); err != nil {
+			return nil, fmt.Errorf("ListarFamilias %s", err)
+		}
+		familias = append(familias, familia)
+	}
+
+	return familias, nil
+}
+
+func (s *sqlStore) FamiliaPorId(ctx context.Context, idText string) (FamiliasResp, error) {
+	var familia FamiliasResp
+
+	row := s.db.QueryRowContext(ctx, "SELECT id, id_text, nombre, miembro_principal, nombre_invitacion, acompanantes FROM Familias WHERE id_text = ?", idText)
+
+	err := row.Scan(
+		&familia.Id,
+		&familia.Id_text,
+		&familia.Nombre,
+		&familia.Miembro_principal,
+		&familia.Nombre_invitacion,
+		&familia.Cupo_acompanantes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return familia, errFamiliaNoEncontrada
+	}
+	if err != nil {
+		return familia, fmt.Errorf("FamiliaPorId %s", err)
+	}
+
+	return familia, nil
+}
+
+// validarMiembroPrincipalTx comprueba que el miembro principal sea un invitado
+// existente y que no encabece ya otra familia distinta a idFamilia.
+func validarMiembroPrincipalTx(ctx context.Context, tx *sql.Tx, miembroPrincipal int64, idFamilia int64) error {
+	var existe bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Invitados WHERE id = ?)", miembroPrincipal).Scan(&existe); err != nil {
+		return fmt.Errorf("validarMiembroPrincipalTx %s", err)
+	}
+	if !existe {
+		return errMiembroPrincipalInvalido
+	}
+
+	var encabezaOtra bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Familias WHERE miembro_principal = ? AND id <> ?)", miembroPrincipal, idFamilia).Scan(&encabezaOtra); err != nil {
+		return fmt.Errorf("validarMiembroPrincipalTx %s", err)
+	}
+	if encabezaOtra {
+		return errMiembroPrincipalDeOtraFamilia
+	}
+
+	return nil
+}
+
+func (s *sqlStore) CrearFamilia(ctx context.Context, cmd FamiliasCommand) (FamiliasResp, error) {
+	idText, err := generarIdTextDB(ctx, s.db, "Familias")
+	if err != nil {
+		return FamiliasResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return FamiliasResp{}, fmt.Errorf("CrearFamilia %s", err)
+	}
+	defer tx.Rollback()
+
+	if err := validarMiembroPrincipalTx(ctx, tx, cmd.Miembro_principal, 0); err != nil {
+		return FamiliasResp{}, err
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO Familias (id_text, nombre, miembro_principal, nombre_invitacion) VALUES (?, ?, ?, ?)",
+		idText, cmd.Nombre, cmd.Miembro_principal, cmd.Nombre_invitacion)
+	if err != nil {
+		return FamiliasResp{}, fmt.Errorf("CrearFamilia %s", err)
+	}
+
+	idFamilia, err := res.LastInsertId()
+	if err != nil {
+		return FamiliasResp{}, fmt.Errorf("CrearFamilia %s", err)
+	}
+
+	// El miembro principal siempre pertenece a la familia que encabeza.
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_familia = ? WHERE id = ?", idFamilia, cmd.Miembro_principal); err != nil {
+		return FamiliasResp{}, fmt.Errorf("CrearFamilia %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCrearFamilia, Familia: idText, Nuevo: cmd}); err != nil {
+		return FamiliasResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return FamiliasResp{}, fmt.Errorf("CrearFamilia %s", err)
+	}
+
+	return s.FamiliaPorId(ctx, idText)
+}
+
+func (s *sqlStore) ReemplazarFamilia(ctx context.Context, idText string, cmd FamiliasCommand) (FamiliasResp, error) {
+	familia, err := s.FamiliaPorId(ctx, idText)
+	if err != nil {
+		return FamiliasResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return FamiliasResp{}, fmt.Errorf("ReemplazarFamilia %s", err)
+	}
+	defer tx.Rollback()
+
+	if err := validarMiembroPrincipalTx(ctx, tx, cmd.Miembro_principal, familia.Id); err != nil {
+		return FamiliasResp{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Familias SET nombre = ?, miembro_principal = ?, nombre_invitacion = ? WHERE id = ?",
+		cmd.Nombre, cmd.Miembro_principal, cmd.Nombre_invitacion, familia.Id); err != nil {
+		return FamiliasResp{}, fmt.Errorf("ReemplazarFamilia %s", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_familia = ? WHERE id = ?", familia.Id, cmd.Miembro_principal); err != nil {
+		return FamiliasResp{}, fmt.Errorf("ReemplazarFamilia %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEditarFamilia, Familia: idText, Anterior: familiaAuditada(familia), Nuevo: cmd}); err != nil {
+		return FamiliasResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return FamiliasResp{}, fmt.Errorf("ReemplazarFamilia %s", err)
+	}
+
+	return s.FamiliaPorId(ctx, idText)
+}
+
+// EliminarFamilia borra la familia y los tokens de su enlace. Si todavía tiene
+// invitados solo se elimina cuando cascada es verdadero, y en ese caso se
+// borran también ellos, como en EliminarInvitado.
+func (s *sqlStore) EliminarFamilia(ctx context.Context, idText string, cascada bool) error {
+	familia, err := s.FamiliaPorId(ctx, idText)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("EliminarFamilia %s", err)
+	}
+	defer tx.Rollback()
+
+	var miembros int
+	if err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Invitados WHERE id_familia = ?", familia.Id).Scan(&miembros); err != nil {
+		return fmt.Errorf("EliminarFamilia %s", err)
+	}
+
+	if miembros > 0 && !cascada {
+		return errFamiliaConMiembros
+	}
+
+	if err := eliminarInvitadosTx(ctx, tx, "i.id_familia = ?", familia.Id); err != nil {
+		return err
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEliminarFamilia, Familia: idText, Anterior: familiaAuditada(familia)}); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM TokensInvitacion WHERE tipo = ? AND id_destino = ?", invitacionFamilia, familia.Id); err != nil {
+		return fmt.Errorf("EliminarFamilia %s", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Familias WHERE id = ?", familia.Id); err != nil {
+		return fmt.Errorf("EliminarFamilia %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EliminarFamilia %s", err)
+	}
+
+	return nil
+}
+
+// MoverInvitado no permite que el miembro principal de una familia salga de
+// ella.
+func (s *sqlStore) MoverInvitado(ctx context.Context, idTextInvitado string, idTextFamilia string) (InvitadoResp, error) {
+	invitado, err := s.InvitadoPorId(ctx, idTextInvitado)
+	if err != nil {
+		return InvitadoResp{}, err
+	}
+
+	var idFamilia sql.NullInt64
+	if idTextFamilia != "" {
+		familia, err := s.FamiliaPorId(ctx, idTextFamilia)
+		if err != nil {
+			return InvitadoResp{}, err
+		}
+		idFamilia = sql.NullInt64{Int64: familia.Id, Valid: true}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return InvitadoResp{}, fmt.Errorf("MoverInvitado %s", err)
+	}
+	defer tx.Rollback()
+
+	var esPrincipal bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Familias WHERE miembro_principal = ? AND id <> ?)", invitado.Id, idFamilia.Int64).Scan(&esPrincipal); err != nil {
+		return InvitadoResp{}, fmt.Errorf("MoverInvitado %s", err)
+	}
+	if esPrincipal {
+		return InvitadoResp{}, errInvitadoEsPrincipal
+	}
+
+	var anterior sql.NullString
+	if err := tx.QueryRowContext(ctx, "SELECT f.id_text FROM Invitados i LEFT JOIN Familias f ON f.id = i.id_familia WHERE i.id = ?", invitado.Id).Scan(&anterior); err != nil {
+		return InvitadoResp{}, fmt.Errorf("MoverInvitado %s", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_familia = ? WHERE id = ?", idFamilia, invitado.Id); err != nil {
+		return InvitadoResp{}, fmt.Errorf("MoverInvitado %s", err)
+	}
+
+	// El cambio aparece en la familia a la que llega o, si sale de todas, en
+	// la que deja.
+	cambio := cambioAuditoria{Accion: accionMoverInvitado, Invitado: idTextInvitado, Familia: idTextFamilia,
+		Anterior: textoAuditado(anterior), Nuevo: textoAuditado(sql.NullString{String: idTextFamilia, Valid: idTextFamilia != ""})}
+	if cambio.Familia == "" {
+		cambio.Familia = anterior.String
+	}
+	if err := registrarCambioTx(ctx, tx, cambio); err != nil {
+		return InvitadoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return InvitadoResp{}, fmt.Errorf("MoverInvitado %s", err)
+	}
+
+	return s.InvitadoPorId(ctx, idTextInvitado)
+}
+
+func (s *sqlStore) AgregarCancion(ctx context.Context, idInvitado string, nombreCancion string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("AgregarCancion %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Canciones (id_invitado, fecha, nombre_cancion) VALUES(?, CURRENT_TIMESTAMP, ?)", idInvitado, nombreCancion); err != nil {
+		return fmt.Errorf("AgregarCancion %s", err)
+	}
+	cambio, err := cambioDeRemitenteTx(ctx, tx, accionCancion, idInvitado)
+	if err != nil {
+		return err
+	}
+	cambio.Nuevo = nombreCancion
+	if err := registrarCambioTx(ctx, tx, cambio); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("AgregarCancion %s", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) AgregarMensaje(ctx context.Context, idInvitado string, contenido string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("AgregarMensaje %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Mensajes (id_invitado, fecha, contenido) VALUES(?, CURRENT_TIMESTAMP, ?)", idInvitado, contenido); err != nil {
+		return fmt.Errorf("AgregarMensaje %s", err)
+	}
+	cambio, err := cambioDeRemitenteTx(ctx, tx, accionMensaje, idInvitado)
+	if err != nil {
+		return err
+	}
+	cambio.Nuevo = contenido
+	if err := registrarCambioTx(ctx, tx, cambio); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("AgregarMensaje %s", err)
+	}
+	return nil
+}
+
+// cambioDeRemitenteTx arma el cambio de una canción o un mensaje. Su
+// id_invitado puede ser el de un invitado o, si llegó con el enlace de la
+// familia, el de la familia.
+func cambioDeRemitenteTx(ctx context.Context, ej ejecutor, accion string, idText string) (cambioAuditoria, error) {
+	var esInvitado bool
+	if err := ej.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Invitados WHERE id_text = ?)", idText).Scan(&esInvitado); err != nil {
+		return cambioAuditoria{}, fmt.Errorf("cambioDeRemitenteTx %s", err)
+	}
+	if esInvitado {
+		return cambioAuditoria{Accion: accion, Invitado: idText}, nil
+	}
+	return cambioAuditoria{Accion: accion, Familia: idText}, nil
+}
+
+// AplicarImportacion aplica el plan en una sola transacción. Después de mover
+// invitados, cada familia cuyo miembro principal ya no está en ella pasa a
+// encabezarla su miembro más antiguo; las familias que quedan vacías se
+// eliminan junto con los invitados solo cuando eliminar es verdadero.
+func (s *sqlStore) AplicarImportacion(ctx context.Context, plan planImportacion, eliminar bool) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("AplicarImportacion %s", err)
+	}
+	defer tx.Rollback()
+
+	idPorIdText := map[string]int64{}
+	filas := make([]filaImportacion, len(plan.filas))
+	copy(filas, plan.filas)
+
+	for i, fila := range filas {
+		if fila.Id_text != "" {
+			continue
+		}
+		idText, err := generarIdTextDB(ctx, tx, "Invitados")
+		if err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, "INSERT INTO Invitados (id_text, nombre, nombre_invitacion) VALUES (?, ?, ?)",
+			idText, fila.Nombre, fila.Nombre_invitacion)
+		if err != nil {
+			return fmt.Errorf("AplicarImportacion línea %v %s", fila.Linea, err)
+		}
+		id, err := res.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("AplicarImportacion %s", err)
+		}
+		filas[i].Id_text = idText
+		idPorIdText[idText] = id
+	}
+
+	for _, fila := range plan.Cambiados {
+		if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET nombre = ?, nombre_invitacion = ? WHERE id_text = ?",
+			fila.Nombre, fila.Nombre_invitacion, fila.Id_text); err != nil {
+			return fmt.Errorf("AplicarImportacion línea %v %s", fila.Linea, err)
+		}
+	}
+
+	if plan.Con_familias {
+		if err := asignarFamiliasImportacionTx(ctx, tx, filas, idPorIdText); err != nil {
+			return err
+		}
+	}
+
+	// Los nuevos y los cambiados se registran ya en su familia.
+	for _, fila := range filas {
+		if _, nuevo := idPorIdText[fila.Id_text]; !nuevo {
+			continue
+		}
+		if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionImportarInvitado, Invitado: fila.Id_text, Nuevo: fila}); err != nil {
+			return err
+		}
+	}
+	for _, fila := range plan.Cambiados {
+		if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionImportarInvitado, Invitado: fila.Id_text, Nuevo: fila}); err != nil {
+			return err
+		}
+	}
+
+	if eliminar {
+		for _, fila := range plan.Eliminados {
+			if err := eliminarInvitadosTx(ctx, tx, "i.id_text = ?", fila.Id_text); err != nil {
+				return err
+			}
+		}
+	}
+
+	if plan.Con_familias || eliminar {
+		if err := repararFamiliasTx(ctx, tx, eliminar); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("AplicarImportacion %s", err)
+	}
+
+	return nil
+}
+
+func asignarFamiliasImportacionTx(ctx context.Context, tx *sql.Tx, filas []filaImportacion, idPorIdText map[string]int64) error {
+	idFamiliaPorNombre := map[string]int64{}
+	famResp, err := tx.QueryContext(ctx, "SELECT id, nombre FROM Familias")
+	if err != nil {
+		return fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+	}
+	for famResp.Next() {
+		var id int64
+		var nombre string
+		if err := famResp.Scan(&id, &nombre); err != nil {
+			famResp.Close()
+			return fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+		}
+		idFamiliaPorNombre[claveNombre(nombre)] = id
+	}
+	famResp.Close()
+
+	idInvitado := func(idText string) (int64, error) {
+		if id, ok := idPorIdText[idText]; ok {
+			return id, nil
+		}
+		var id int64
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM Invitados WHERE id_text = ?", idText).Scan(&id); err != nil {
+			return 0, fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+		}
+		idPorIdText[idText] = id
+		return id, nil
+	}
+
+	for _, fila := range filas {
+		id, err := idInvitado(fila.Id_text)
+		if err != nil {
+			return err
+		}
+
+		if fila.Familia == "" {
+			if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_familia = NULL WHERE id = ?", id); err != nil {
+				return fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+			}
+			continue
+		}
+
+		idFamilia, existe := idFamiliaPorNombre[claveNombre(fila.Familia)]
+		if !existe {
+			idText, err := generarIdTextDB(ctx, tx, "Familias")
+			if err != nil {
+				return err
+			}
+			res, err := tx.ExecContext(ctx, "INSERT INTO Familias (id_text, nombre, miembro_principal, nombre_invitacion) VALUES (?, ?, ?, ?)",
+				idText, fila.Familia, id, fila.Familia)
+			if err != nil {
+				return fmt.Errorf("asignarFamiliasImportacionTx línea %v %s", fila.Linea, err)
+			}
+			if idFamilia, err = res.LastInsertId(); err != nil {
+				return fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+			}
+			idFamiliaPorNombre[claveNombre(fila.Familia)] = idFamilia
+		}
+
+		if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_familia = ? WHERE id = ?", idFamilia, id); err != nil {
+			return fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+		}
+
+		if fila.Miembro_principal {
+			if _, err := tx.ExecContext(ctx, "UPDATE Familias SET miembro_principal = ? WHERE id = ?", id, idFamilia); err != nil {
+				return fmt.Errorf("asignarFamiliasImportacionTx %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// repararFamiliasTx deja a cada familia encabezada por uno de sus miembros.
+func repararFamiliasTx(ctx context.Context, tx *sql.Tx, eliminarVacias bool) error {
+	type familiaSinPrincipal struct {
+		id            int64
+		primerMiembro sql.NullInt64
+	}
+
+	filas, err := tx.QueryContext(ctx, `SELECT f.id, (SELECT MIN(i.id) FROM Invitados i WHERE i.id_familia = f.id)
+		FROM Familias f
+		WHERE NOT EXISTS (SELECT 1 FROM Invitados p WHERE p.id = f.miembro_principal AND p.id_familia = f.id)`)
+	if err != nil {
+		return fmt.Errorf("repararFamiliasTx %s", err)
+	}
+	var pendientes []familiaSinPrincipal
+	for filas.Next() {
+		var f familiaSinPrincipal
+		if err := filas.Scan(&f.id, &f.primerMiembro); err != nil {
+			filas.Close()
+			return fmt.Errorf("repararFamiliasTx %s", err)
+		}
+		pendientes = append(pendientes, f)
+	}
+	filas.Close()
+
+	for _, f := range pendientes {
+		switch {
+		case f.primerMiembro.Valid:
+			if _, err := tx.ExecContext(ctx, "UPDATE Familias SET miembro_principal = ? WHERE id = ?", f.primerMiembro.Int64, f.id); err != nil {
+				return fmt.Errorf("repararFamiliasTx %s", err)
+			}
+		case eliminarVacias:
+			if _, err := tx.ExecContext(ctx, "DELETE FROM Familias WHERE id = ?", f.id); err != nil {
+				return fmt.Errorf("repararFamiliasTx %s", err)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (s *sqlStore) CrearUsuario(ctx context.Context, nombre string, hashClave string, rol string) (Usuario, error) {
+	var existe bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Usuarios WHERE nombre = ?)", nombre).Scan(&existe); err != nil {
+		return Usuario{}, fmt.Errorf("CrearUsuario %s", err)
+	}
+	if existe {
+		return Usuario{}, errUsuarioExistente
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Usuario{}, fmt.Errorf("CrearUsuario %s", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO Usuarios (nombre, hash_clave, rol, creado_en) VALUES(?, ?, ?, CURRENT_TIMESTAMP)", nombre, hashClave, rol)
+	if err != nil {
+		return Usuario{}, fmt.Errorf("CrearUsuario %s", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return Usuario{}, fmt.Errorf("CrearUsuario %s", err)
+	}
+	usuario := Usuario{Id: id, Nombre: nombre, Rol: rol}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCrearUsuario, Recurso: recursoUsuario, Id_recurso: strconv.FormatInt(id, 10), Nuevo: usuario}); err != nil {
+		return Usuario{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Usuario{}, fmt.Errorf("CrearUsuario %s", err)
+	}
+
+	return usuario, nil
+}
+
+func (s *sqlStore) ListarUsuarios(ctx context.Context) ([]Usuario, error) {
+	filas, err := s.db.QueryContext(ctx, "SELECT id, nombre, rol FROM Usuarios ORDER BY nombre")
+	if err != nil {
+		return nil, fmt.Errorf("ListarUsuarios %s", err)
+	}
+	defer filas.Close()
+
+	usuarios := []Usuario{}
+	for filas.Next() {
+		var usuario Usuario
+		if err := filas.Scan(&usuario.Id, &usuario.Nombre, &usuario.Rol); err != nil {
+			return nil, fmt.Errorf("ListarUsuarios %s", err)
+		}
+		usuarios = append(usuarios, usuario)
+	}
+
+	return usuarios, filas.Err()
+}
+
+func (s *sqlStore) UsuarioPorNombre(ctx context.Context, nombre string) (Usuario, string, error) {
+	var usuario Usuario
+	var hashClave string
+
+	err := s.db.QueryRowContext(ctx, "SELECT id, nombre, rol, hash_clave FROM Usuarios WHERE nombre = ?", nombre).
+		Scan(&usuario.Id, &usuario.Nombre, &usuario.Rol, &hashClave)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usuario, "", errUsuarioNoEncontrado
+	}
+	if err != nil {
+		return usuario, "", fmt.Errorf("UsuarioPorNombre %s", err)
+	}
+
+	return usuario, hashClave, nil
+}
+
+func (s *sqlStore) CrearSesion(ctx context.Context, idUsuario int64, hashToken string, expira time.Time) error {
+	// Se aprovecha para limpiar las sesiones vencidas y que la tabla no crezca.
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM Sesiones WHERE expira_en < ?", time.Now().Unix()); err != nil {
+		return fmt.Errorf("CrearSesion %s", err)
+	}
+	if _, err := s.db.ExecContext(ctx, "INSERT INTO Sesiones (id_usuario, hash_token, expira_en) VALUES(?, ?, ?)", idUsuario, hashToken, expira.Unix()); err != nil {
+		return fmt.Errorf("CrearSesion %s", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) UsuarioPorSesion(ctx context.Context, hashToken string) (Usuario, error) {
+	var usuario Usuario
+	var expiraEn int64
+
+	err := s.db.QueryRowContext(ctx, "SELECT u.id, u.nombre, u.rol, se.expira_en FROM Sesiones se INNER JOIN Usuarios u ON se.id_usuario = u.id WHERE se.hash_token = ?", hashToken).
+		Scan(&usuario.Id, &usuario.Nombre, &usuario.Rol, &expiraEn)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usuario, errCredencialInvalida
+	}
+	if err != nil {
+		return usuario, fmt.Errorf("UsuarioPorSesion %s", err)
+	}
+	if time.Now().Unix() >= expiraEn {
+		return usuario, errCredencialInvalida
+	}
+
+	return usuario, nil
+}
+
+func (s *sqlStore) EliminarSesion(ctx context.Context, hashToken string) error {
+	if _, err := s.db.ExecContext(ctx, "DELETE FROM Sesiones WHERE hash_token = ?", hashToken); err != nil {
+		return fmt.Errorf("EliminarSesion %s", err)
+	}
+	return nil
+}
+
+func (s *sqlStore) CrearTokenApi(ctx context.Context, idUsuario int64, nombre string, hashToken string) (int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("CrearTokenApi %s", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO TokensApi (id_usuario, nombre, hash_token, creado_en) VALUES(?, ?, ?, CURRENT_TIMESTAMP)", idUsuario, nombre, hashToken)
+	if err != nil {
+		return 0, fmt.Errorf("CrearTokenApi %s", err)
+	}
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("CrearTokenApi %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCrearTokenApi, Recurso: recursoTokenApi, Id_recurso: strconv.FormatInt(id, 10),
+		Nuevo: tokenApiAuditado{Usuario: idUsuario, Nombre: nombre}}); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("CrearTokenApi %s", err)
+	}
+	return id, nil
+}
+
+func (s *sqlStore) UsuarioPorTokenApi(ctx context.Context, hashToken string) (Usuario, error) {
+	var usuario Usuario
+
+	err := s.db.QueryRowContext(ctx, "SELECT u.id, u.nombre, u.rol FROM TokensApi t INNER JOIN Usuarios u ON t.id_usuario = u.id WHERE t.hash_token = ? AND t.revocado_en IS NULL", hashToken).
+		Scan(&usuario.Id, &usuario.Nombre, &usuario.Rol)
+	if errors.Is(err, sql.ErrNoRows) {
+		return usuario, errCredencialInvalida
+	}
+	if err != nil {
+		return usuario, fmt.Errorf("UsuarioPorTokenApi %s", err)
+	}
+
+	return usuario, nil
+}
+
+func (s *sqlStore) RevocarTokenApi(ctx context.Context, idUsuario int64, idToken int64) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("RevocarTokenApi %s", err)
+	}
+	defer tx.Rollback()
+
+	var token tokenApiAuditado
+	err = tx.QueryRowContext(ctx, "SELECT id_usuario, nombre FROM TokensApi WHERE id = ? AND id_usuario = ? AND revocado_en IS NULL", idToken, idUsuario).
+		Scan(&token.Usuario, &token.Nombre)
+	if errors.Is(err, sql.ErrNoRows) {
+		return errTokenApiNoEncontrado
+	}
+	if err != nil {
+		return fmt.Errorf("RevocarTokenApi %s", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE TokensApi SET revocado_en = CURRENT_TIMESTAMP WHERE id = ?", idToken); err != nil {
+		return fmt.Errorf("RevocarTokenApi %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionRevocarTokenApi, Recurso: recursoTokenApi, Id_recurso: strconv.FormatInt(idToken, 10), Anterior: token}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RevocarTokenApi %s", err)
+	}
+	return nil
+}
+
+// destinoInvitacion busca el id interno y el nombre del invitado o la familia.
+func destinoInvitacion(ctx context.Context, ej ejecutor, tipo string, idText string) (int64, string, error) {
+	tabla, noEncontrado := "Invitados", errInvitadoNoEncontrado
+	if tipo == invitacionFamilia {
+		tabla, noEncontrado = "Familias", errFamiliaNoEncontrada
+	}
+
+	var id int64
+	var nombre string
+	err := ej.QueryRowContext(ctx, "SELECT id, nombre FROM "+tabla+" WHERE id_text = ?", idText).Scan(&id, &nombre)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, "", noEncontrado
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("destinoInvitacion %s", err)
+	}
+
+	return id, nombre, nil
+}
+
+// destinoEmisible es destinoInvitacion para emitir un token. Los acompañantes
+// responden con el enlace de quien los nombró y no tienen uno propio, así que
+// devuelve errInvitadoEsAcompanante.
+func destinoEmisible(ctx context.Context, ej ejecutor, tipo string, idText string) (int64, string, error) {
+	id, nombre, err := destinoInvitacion(ctx, ej, tipo, idText)
+	if err != nil || tipo == invitacionFamilia {
+		return id, nombre, err
+	}
+
+	var acompanante bool
+	if err := ej.QueryRowContext(ctx, "SELECT id_anfitrion IS NOT NULL FROM Invitados WHERE id = ?", id).Scan(&acompanante); err != nil {
+		return 0, "", fmt.Errorf("destinoEmisible %s", err)
+	}
+	if acompanante {
+		return 0, "", errInvitadoEsAcompanante
+	}
+
+	return id, nombre, nil
+}
+
+func emitirInvitacion(ctx context.Context, ej ejecutor, tipo string, idDestino int64, expira time.Time) (string, error) {
+	token, err := generarTokenInvitacion()
+	if err != nil {
+		return "", err
+	}
+
+	var expiraEn sql.NullInt64
+	if !expira.IsZero() {
+		expiraEn = sql.NullInt64{Int64: expira.Unix(), Valid: true}
+	}
+
+	if _, err := ej.ExecContext(ctx, "INSERT INTO TokensInvitacion (token, tipo, id_destino, creado_en, expira_en) VALUES(?, ?, ?, CURRENT_TIMESTAMP, ?)", token, tipo, idDestino, expiraEn); err != nil {
+		return "", fmt.Errorf("emitirInvitacion %s", err)
+	}
+
+	return token, nil
+}
+
+// consultaInvitaciones trae los tokens no revocados con el id_text y el nombre
+// de su destino. Si el invitado o la familia ya no existe, el id_text es NULL.
+const consultaInvitaciones = `SELECT t.token, t.tipo, t.expira_en, COALESCE(i.id_text, f.id_text), COALESCE(i.nombre, f.nombre)
+	FROM TokensInvitacion t
+	LEFT JOIN Invitados i ON t.tipo = 'invitado' AND i.id = t.id_destino
+	LEFT JOIN Familias f ON t.tipo = 'familia' AND f.id = t.id_destino
+	WHERE t.revocado_en IS NULL`
+
+// escanearInvitacion devuelve falso si el token expiró o su destino ya no existe.
+func escanearInvitacion(fila interface{ Scan(...any) error }) (Invitacion, bool, error) {
+	var invitacion Invitacion
+	var expiraEn sql.NullInt64
+	var idText, nombre sql.NullString
+
+	if err := fila.Scan(&invitacion.Token, &invitacion.Tipo, &expiraEn, &idText, &nombre); err != nil {
+		return invitacion, false, err
+	}
+
+	invitacion.Id_text = idText.String
+	invitacion.Nombre = nombre.String
+	if expiraEn.Valid {
+		expira := time.Unix(expiraEn.Int64, 0).UTC()
+		invitacion.Expira_en = &expira
+		if !time.Now().Before(expira) {
+			return invitacion, false, nil
+		}
+	}
+
+	return invitacion, idText.Valid, nil
+}
+
+func (s *sqlStore) ResolverInvitacion(ctx context.Context, token string) (Invitacion, error) {
+	invitacion, vigente, err := escanearInvitacion(s.db.QueryRowContext(ctx, consultaInvitaciones+" AND t.token = ?", token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return invitacion, errInvitacionInvalida
+	}
+	if err != nil {
+		return invitacion, fmt.Errorf("ResolverInvitacion %s", err)
+	}
+	if !vigente {
+		return invitacion, errInvitacionInvalida
+	}
+
+	return invitacion, nil
+}
+
+func (s *sqlStore) TokenInvitacion(ctx context.Context, tipo string, idText string) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("TokenInvitacion %s", err)
+	}
+	defer tx.Rollback()
+
+	idDestino, _, err := destinoEmisible(ctx, tx, tipo, idText)
+	if err != nil {
+		return "", err
+	}
+
+	var token string
+	var expiraEn sql.NullInt64
+	var revocado bool
+	err = tx.QueryRowContext(ctx, "SELECT token, expira_en, revocado_en IS NOT NULL FROM TokensInvitacion WHERE tipo = ? AND id_destino = ? ORDER BY id DESC LIMIT 1", tipo, idDestino).
+		Scan(&token, &expiraEn, &revocado)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		token, err = emitirInvitacion(ctx, tx, tipo, idDestino, time.Time{})
+		if err != nil {
+			return "", err
+		}
+	case err != nil:
+		return "", fmt.Errorf("TokenInvitacion %s", err)
+	case revocado || (expiraEn.Valid && time.Now().Unix() >= expiraEn.Int64):
+		return "", errInvitacionInvalida
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("TokenInvitacion %s", err)
+	}
+
+	return token, nil
+}
+
+func (s *sqlStore) RotarInvitacion(ctx context.Context, tipo string, idText string, expira time.Time) (Invitacion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Invitacion{}, fmt.Errorf("RotarInvitacion %s", err)
+	}
+	defer tx.Rollback()
+
+	idDestino, nombre, err := destinoEmisible(ctx, tx, tipo, idText)
+	if err != nil {
+		return Invitacion{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE TokensInvitacion SET revocado_en = CURRENT_TIMESTAMP WHERE tipo = ? AND id_destino = ? AND revocado_en IS NULL", tipo, idDestino); err != nil {
+		return Invitacion{}, fmt.Errorf("RotarInvitacion %s", err)
+	}
+
+	token, err := emitirInvitacion(ctx, tx, tipo, idDestino, expira)
+	if err != nil {
+		return Invitacion{}, err
+	}
+
+	cambio := cambioDeInvitacion(accionRotarInvitacion, tipo, idText)
+	if !expira.IsZero() {
+		cambio.Nuevo = InvitacionCommand{Expira_en: &expira}
+	}
+	if err := registrarCambioTx(ctx, tx, cambio); err != nil {
+		return Invitacion{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Invitacion{}, fmt.Errorf("RotarInvitacion %s", err)
+	}
+
+	invitacion := Invitacion{Token: token, Tipo: tipo, Id_text: idText, Nombre: nombre}
+	if !expira.IsZero() {
+		expiraEn := time.Unix(expira.Unix(), 0).UTC()
+		invitacion.Expira_en = &expiraEn
+	}
+	return invitacion, nil
+}
+
+func (s *sqlStore) RevocarInvitacion(ctx context.Context, tipo string, idText string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("RevocarInvitacion %s", err)
+	}
+	defer tx.Rollback()
+
+	idDestino, _, err := destinoInvitacion(ctx, tx, tipo, idText)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE TokensInvitacion SET revocado_en = CURRENT_TIMESTAMP WHERE tipo = ? AND id_destino = ? AND revocado_en IS NULL", tipo, idDestino); err != nil {
+		return fmt.Errorf("RevocarInvitacion %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioDeInvitacion(accionRevocarInvitacion, tipo, idText)); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("RevocarInvitacion %s", err)
+	}
+	return nil
+}
+
+// cambioDeInvitacion arma el cambio al invitado o a la familia de un token.
+func cambioDeInvitacion(accion string, tipo string, idText string) cambioAuditoria {
+	if tipo == invitacionFamilia {
+		return cambioAuditoria{Accion: accion, Familia: idText}
+	}
+	return cambioAuditoria{Accion: accion, Invitado: idText}
+}
+
+func (s *sqlStore) ListarInvitaciones(ctx context.Context) ([]Invitacion, error) {
+	filas, err := s.db.QueryContext(ctx, consultaInvitaciones+" ORDER BY t.tipo, t.id")
+	if err != nil {
+		return nil, fmt.Errorf("ListarInvitaciones %s", err)
+	}
+	defer filas.Close()
+
+	invitaciones := []Invitacion{}
+	for filas.Next() {
+		invitacion, vigente, err := escanearInvitacion(filas)
+		if err != nil {
+			return nil, fmt.Errorf("ListarInvitaciones %s", err)
+		}
+		if vigente {
+			invitaciones = append(invitaciones, invitacion)
+		}
+	}
+
+	return invitaciones, filas.Err()
+}
+
+const columnasEvento = "e.id, e.id_text, e.nombre, e.lugar, e.fecha, e.orden, e.limite_rsvp, e.sin_menu"
+
+// escanearEvento lee columnasEvento y después los destinos de extra.
+func escanearEvento(fila interface{ Scan(...any) error }, extra ...any) (EventoResp, error) {
+	var evento EventoResp
+	var fecha, limite sql.NullInt64
+
+	destinos := append([]any{&evento.Id, &evento.Id_text, &evento.Nombre, &evento.Lugar, &fecha, &evento.Orden, &limite, &evento.Sin_menu}, extra...)
+	if err := fila.Scan(destinos...); err != nil {
+		return evento, err
+	}
+
+	evento.Fecha = fechaUnix(fecha)
+	evento.Limite_rsvp = fechaUnix(limite)
+	return evento, nil
+}
+
+func fechaUnix(columna sql.NullInt64) *time.Time {
+	if !columna.Valid {
+		return nil
+	}
+	f := time.Unix(columna.Int64, 0).UTC()
+	return &f
+}
+
+func fechaEvento(fecha *time.Time) sql.NullInt64 {
+	if fecha == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: fecha.Unix(), Valid: true}
+}
+
+func idEvento(ctx context.Context, ej ejecutor, idText string) (int64, error) {
+	var id int64
+	err := ej.QueryRowContext(ctx, "SELECT id FROM Eventos WHERE id_text = ?", idText).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errEventoNoEncontrado
+	}
+	if err != nil {
+		return 0, fmt.Errorf("idEvento %s", err)
+	}
+	return id, nil
+}
+
+// sincronizarAsistenciaTx resume en Invitados.asiste las respuestas del
+// invitado a sus eventos. Un invitado sin eventos conserva su asiste.
+func sincronizarAsistenciaTx(ctx context.Context, ej ejecutor, idInvitado int64) error {
+	var eventos, respondidos, acepto int
+	if err := ej.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(asiste), COALESCE(MAX(asiste), 0) FROM InvitacionesEvento WHERE id_invitado = ?", idInvitado).
+		Scan(&eventos, &respondidos, &acepto); err != nil {
+		return fmt.Errorf("sincronizarAsistenciaTx %s", err)
+	}
+	if eventos == 0 {
+		return nil
+	}
+
+	var asiste sql.NullBool
+	switch {
+	case acepto == 1:
+		asiste = sql.NullBool{Bool: true, Valid: true}
+	case respondidos == eventos:
+		asiste = sql.NullBool{Bool: false, Valid: true}
+	}
+
+	if _, err := ej.ExecContext(ctx, "UPDATE Invitados SET asiste = ? WHERE id = ?", asiste, idInvitado); err != nil {
+		return fmt.Errorf("sincronizarAsistenciaTx %s", err)
+	}
+	return reflejarEnAcompanantesTx(ctx, ej, idInvitado, asiste)
+}
+
+func (s *sqlStore) ListarEventos(ctx context.Context) ([]EventoResp, error) {
+	filas, err := s.db.QueryContext(ctx, "SELECT "+columnasEvento+" FROM Eventos e ORDER BY e.orden, e.id")
+	if err != nil {
+		return nil, fmt.Errorf("ListarEventos %s", err)
+	}
+	defer filas.Close()
+
+	eventos := []EventoResp{}
+	for filas.Next() {
+		evento, err := escanearEvento(filas)
+		if err != nil {
+			return nil, fmt.Errorf("ListarEventos %s", err)
+		}
+		eventos = append(eventos, evento)
+	}
+
+	return eventos, filas.Err()
+}
+
+func (s *sqlStore) EventoPorId(ctx context.Context, idText string) (EventoResp, error) {
+	evento, err := escanearEvento(s.db.QueryRowContext(ctx, "SELECT "+columnasEvento+" FROM Eventos e WHERE e.id_text = ?", idText))
+	if errors.Is(err, sql.ErrNoRows) {
+		return evento, errEventoNoEncontrado
+	}
+	if err != nil {
+		return evento, fmt.Errorf("EventoPorId %s", err)
+	}
+
+	return evento, nil
+}
+
+func (s *sqlStore) CrearEvento(ctx context.Context, cmd EventoCommand) (EventoResp, error) {
+	idText, err := generarIdTextDB(ctx, s.db, "Eventos")
+	if err != nil {
+		return EventoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return EventoResp{}, fmt.Errorf("CrearEvento %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Eventos (id_text, nombre, lugar, fecha, orden, limite_rsvp, sin_menu) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		idText, cmd.Nombre, cmd.Lugar, fechaEvento(cmd.Fecha), cmd.Orden, fechaEvento(cmd.Limite_rsvp), cmd.Sin_menu); err != nil {
+		return EventoResp{}, fmt.Errorf("CrearEvento %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCrearEvento, Recurso: recursoEvento, Id_recurso: idText, Nuevo: cmd}); err != nil {
+		return EventoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return EventoResp{}, fmt.Errorf("CrearEvento %s", err)
+	}
+
+	return s.EventoPorId(ctx, idText)
+}
+
+func (s *sqlStore) ReemplazarEvento(ctx context.Context, idText string, cmd EventoCommand) (EventoResp, error) {
+	evento, err := s.EventoPorId(ctx, idText)
+	if err != nil {
+		return EventoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return EventoResp{}, fmt.Errorf("ReemplazarEvento %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Eventos SET nombre = ?, lugar = ?, fecha = ?, orden = ?, limite_rsvp = ?, sin_menu = ? WHERE id = ?",
+		cmd.Nombre, cmd.Lugar, fechaEvento(cmd.Fecha), cmd.Orden, fechaEvento(cmd.Limite_rsvp), cmd.Sin_menu, evento.Id); err != nil {
+		return EventoResp{}, fmt.Errorf("ReemplazarEvento %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEditarEvento, Recurso: recursoEvento, Id_recurso: idText, Anterior: eventoAuditado(evento), Nuevo: cmd}); err != nil {
+		return EventoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return EventoResp{}, fmt.Errorf("ReemplazarEvento %s", err)
+	}
+
+	return s.EventoPorId(ctx, idText)
+}
+
+func (s *sqlStore) EliminarEvento(ctx context.Context, idText string) error {
+	evento, err := s.EventoPorId(ctx, idText)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("EliminarEvento %s", err)
+	}
+	defer tx.Rollback()
+
+	id := evento.Id
+	filas, err := tx.QueryContext(ctx, "SELECT i.id, i.id_text FROM InvitacionesEvento ie INNER JOIN Invitados i ON i.id = ie.id_invitado WHERE ie.id_evento = ?", id)
+	if err != nil {
+		return fmt.Errorf("EliminarEvento %s", err)
+	}
+	var invitados []int64
+	var idsText []string
+	for filas.Next() {
+		var idInvitado int64
+		var idTextInvitado string
+		if err := filas.Scan(&idInvitado, &idTextInvitado); err != nil {
+			filas.Close()
+			return fmt.Errorf("EliminarEvento %s", err)
+		}
+		invitados = append(invitados, idInvitado)
+		idsText = append(idsText, idTextInvitado)
+	}
+	filas.Close()
+	if err := filas.Err(); err != nil {
+		return fmt.Errorf("EliminarEvento %s", err)
+	}
+
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEliminarEvento, Recurso: recursoEvento, Id_recurso: idText, Anterior: eventoAuditado(evento)}); err != nil {
+		return err
+	}
+	for _, idTextInvitado := range idsText {
+		if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionQuitarDeEvento, Invitado: idTextInvitado, Anterior: idText}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM InvitacionesEvento WHERE id_evento = ?", id); err != nil {
+		return fmt.Errorf("EliminarEvento %s", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Eventos WHERE id = ?", id); err != nil {
+		return fmt.Errorf("EliminarEvento %s", err)
+	}
+
+	for _, idInvitado := range invitados {
+		if err := sincronizarAsistenciaTx(ctx, tx, idInvitado); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EliminarEvento %s", err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) InvitadosDeEvento(ctx context.Context, idTextEvento string) ([]InvitadoResp, error) {
+	id, err := idEvento(ctx, s.db, idTextEvento)
+	if err != nil {
+		return nil, err
+	}
+
+	filas, err := s.db.QueryContext(ctx, `SELECT i.id, i.id_text, i.nombre, i.nombre_invitacion, ie.asiste, i.acompanantes, a.id_text, i.mesa, i.prorroga_rsvp
+		FROM InvitacionesEvento ie INNER JOIN Invitados i ON i.id = ie.id_invitado
+		LEFT JOIN Invitados a ON a.id = i.id_anfitrion
+		WHERE ie.id_evento = ? ORDER BY i.id`, id)
+	if err != nil {
+		return nil, fmt.Errorf("InvitadosDeEvento %s", err)
+	}
+	defer filas.Close()
+
+	invitados := []InvitadoResp{}
+	for filas.Next() {
+		invitado, err := escanearInvitado(filas)
+		if err != nil {
+			return nil, fmt.Errorf("InvitadosDeEvento %s", err)
+		}
+		invitados = append(invitados, invitado)
+	}
+
+	return invitados, filas.Err()
+}
+
+func (s *sqlStore) InvitarAEvento(ctx context.Context, idTextEvento string, idTextInvitado string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("InvitarAEvento %s", err)
+	}
+	defer tx.Rollback()
+
+	id, err := idEvento(ctx, tx, idTextEvento)
+	if err != nil {
+		return err
+	}
+	idInvitado, _, err := destinoInvitacion(ctx, tx, invitacionInvitado, idTextInvitado)
+	if err != nil {
+		return err
+	}
+
+	var invitado bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM InvitacionesEvento WHERE id_evento = ? AND id_invitado = ?)", id, idInvitado).Scan(&invitado); err != nil {
+		return fmt.Errorf("InvitarAEvento %s", err)
+	}
+	if invitado {
+		return nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO InvitacionesEvento (id_evento, id_invitado) VALUES (?, ?)", id, idInvitado); err != nil {
+		return fmt.Errorf("InvitarAEvento %s", err)
+	}
+	if err := sincronizarAsistenciaTx(ctx, tx, idInvitado); err != nil {
+		return err
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionInvitarAEvento, Invitado: idTextInvitado, Nuevo: idTextEvento}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("InvitarAEvento %s", err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) QuitarDeEvento(ctx context.Context, idTextEvento string, idTextInvitado string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("QuitarDeEvento %s", err)
+	}
+	defer tx.Rollback()
+
+	id, err := idEvento(ctx, tx, idTextEvento)
+	if err != nil {
+		return err
+	}
+	idInvitado, _, err := destinoInvitacion(ctx, tx, invitacionInvitado, idTextInvitado)
+	if err != nil {
+		return err
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM InvitacionesEvento WHERE id_evento = ? AND id_invitado = ?", id, idInvitado)
+	if err != nil {
+		return fmt.Errorf("QuitarDeEvento %s", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return fmt.Errorf("QuitarDeEvento %s", err)
+	} else if n == 0 {
+		return errNoInvitadoAEvento
+	}
+	if err := sincronizarAsistenciaTx(ctx, tx, idInvitado); err != nil {
+		return err
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionQuitarDeEvento, Invitado: idTextInvitado, Anterior: idTextEvento}); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("QuitarDeEvento %s", err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) EventosDeInvitado(ctx context.Context, idTextInvitado string) ([]AsistenciaEvento, error) {
+	idInvitado, _, err := destinoInvitacion(ctx, s.db, invitacionInvitado, idTextInvitado)
+	if err != nil {
+		return nil, err
+	}
+
+	filas, err := s.db.QueryContext(ctx, "SELECT "+columnasEvento+`, ie.asiste
+		FROM InvitacionesEvento ie INNER JOIN Eventos e ON e.id = ie.id_evento
+		WHERE ie.id_invitado = ? ORDER BY e.orden, e.id`, idInvitado)
+	if err != nil {
+		return nil, fmt.Errorf("EventosDeInvitado %s", err)
+	}
+	defer filas.Close()
+
+	eventos := []AsistenciaEvento{}
+	for filas.Next() {
+		var asiste sql.NullBool
+		evento, err := escanearEvento(filas, &asiste)
+		if err != nil {
+			return nil, fmt.Errorf("EventosDeInvitado %s", err)
+		}
+		asistencia := AsistenciaEvento{Evento: evento}
+		if asiste.Valid {
+			asistencia.Asiste = &asiste.Bool
+		}
+		eventos = append(eventos, asistencia)
+	}
+
+	return eventos, filas.Err()
+}
+
+func (s *sqlStore) RegistrarAsistenciaEvento(ctx context.Context, idTextInvitado string, idTextEvento string, asiste bool) (string, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("RegistrarAsistenciaEvento %s", err)
+	}
+	defer tx.Rollback()
+
+	id, err := idEvento(ctx, tx, idTextEvento)
+	if err != nil {
+		return "", err
+	}
+	idInvitado, _, err := destinoInvitacion(ctx, tx, invitacionInvitado, idTextInvitado)
+	if err != nil {
+		return "", err
+	}
+
+	var actual sql.NullBool
+	err = tx.QueryRowContext(ctx, "SELECT asiste FROM InvitacionesEvento WHERE id_evento = ? AND id_invitado = ?", id, idInvitado).Scan(&actual)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errNoInvitadoAEvento
+	}
+	if err != nil {
+		return "", fmt.Errorf("RegistrarAsistenciaEvento %s", err)
+	}
+
+	if actual.Valid && actual.Bool == asiste {
+		return asistenciaSinCambios, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE InvitacionesEvento SET asiste = ? WHERE id_evento = ? AND id_invitado = ?", asiste, id, idInvitado); err != nil {
+		return "", fmt.Errorf("RegistrarAsistenciaEvento %s", err)
+	}
+	if err := sincronizarAsistenciaTx(ctx, tx, idInvitado); err != nil {
+		return "", err
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionAsistenciaEvento, Invitado: idTextInvitado,
+		Anterior: AsistenciaAuditada{Evento: idTextEvento, Asiste: asisteAuditada(actual)},
+		Nuevo:    AsistenciaAuditada{Evento: idTextEvento, Asiste: &asiste}}); err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("RegistrarAsistenciaEvento %s", err)
+	}
+
+	return asistenciaActualizada, nil
+}
+
+// TotalesPorEvento cuenta las respuestas de cada evento, incluidos los que
+// todavía no tienen invitados. Los acompañantes van al evento con su
+// anfitrión, así que cuentan en el total y en los aceptados cuando él acepta.
+func (s *sqlStore) TotalesPorEvento(ctx context.Context) ([]totalesEvento, error) {
+	filas, err := s.db.QueryContext(ctx, `SELECT e.id_text, e.nombre, COUNT(ie.id_invitado),
+		COALESCE(SUM(CASE WHEN ie.id_invitado IS NOT NULL AND ie.asiste IS NULL THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN ie.asiste = 0 THEN 1 ELSE 0 END), 0),
+		COALESCE(SUM(CASE WHEN ie.asiste = 1 THEN 1 ELSE 0 END), 0),
+		(SELECT COUNT(*) FROM Invitados a INNER JOIN InvitacionesEvento h ON h.id_invitado = a.id_anfitrion
+			WHERE h.id_evento = e.id AND h.asiste = 1)
+		FROM Eventos e
+		LEFT JOIN InvitacionesEvento ie ON ie.id_evento = e.id AND ie.id_invitado IN (SELECT id FROM Invitados)
+		GROUP BY e.id, e.id_text, e.nombre, e.orden
+		ORDER BY e.orden, e.id`)
+	if err != nil {
+		return nil, fmt.Errorf("TotalesPorEvento %s", err)
+	}
+	defer filas.Close()
+
+	totales := []totalesEvento{}
+	for filas.Next() {
+		var t totalesEvento
+		if err := filas.Scan(&t.Id_text, &t.Nombre, &t.Total, &t.Sin_respuesta, &t.Rechazados, &t.Aceptados, &t.Acompanantes); err != nil {
+			return nil, fmt.Errorf("TotalesPorEvento %s", err)
+		}
+		t.Total += t.Acompanantes
+		t.Aceptados += t.Acompanantes
+		totales = append(totales, t)
+	}
+
+	return totales, filas.Err()
+}
+
+// anfitrion son los datos de un invitado que hacen falta para sus
+// acompañantes.
+type anfitrion struct {
+	id            int64
+	asiste        sql.NullBool
+	acompanantes  int
+	idFamilia     sql.NullInt64
+	esAcompanante bool
+}
+
+func anfitrionTx(ctx context.Context, ej ejecutor, idText string) (anfitrion, error) {
+	var a anfitrion
+	err := ej.QueryRowContext(ctx, "SELECT id, asiste, acompanantes, id_familia, id_anfitrion IS NOT NULL FROM Invitados WHERE id_text = ?", idText).
+		Scan(&a.id, &a.asiste, &a.acompanantes, &a.idFamilia, &a.esAcompanante)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, errInvitadoNoEncontrado
+	}
+	if err != nil {
+		return a, fmt.Errorf("anfitrionTx %s", err)
+	}
+	return a, nil
+}
+
+// cupoAcompanantesTx calcula cuántos acompañantes puede tener el anfitrión:
+// los suyos más los de su familia que no usaron ya los demás miembros por
+// encima de su propio cupo.
+func cupoAcompanantesTx(ctx context.Context, ej ejecutor, a anfitrion) (int, error) {
+	if a.esAcompanante {
+		return 0, nil
+	}
+	if !a.idFamilia.Valid {
+		return a.acompanantes, nil
+	}
+
+	var cupoFamilia int
+	if err := ej.QueryRowContext(ctx, "SELECT acompanantes FROM Familias WHERE id = ?", a.idFamilia.Int64).Scan(&cupoFamilia); err != nil {
+		return 0, fmt.Errorf("cupoAcompanantesTx %s", err)
+	}
+
+	filas, err := ej.QueryContext(ctx, `SELECT m.acompanantes, (SELECT COUNT(*) FROM Invitados c WHERE c.id_anfitrion = m.id)
+		FROM Invitados m WHERE m.id_familia = ? AND m.id <> ? AND m.id_anfitrion IS NULL`, a.idFamilia.Int64, a.id)
+	if err != nil {
+		return 0, fmt.Errorf("cupoAcompanantesTx %s", err)
+	}
+	defer filas.Close()
+
+	usadosFamilia := 0
+	for filas.Next() {
+		var propios, nombrados int
+		if err := filas.Scan(&propios, &nombrados); err != nil {
+			return 0, fmt.Errorf("cupoAcompanantesTx %s", err)
+		}
+		if nombrados > propios {
+			usadosFamilia += nombrados - propios
+		}
+	}
+	if err := filas.Err(); err != nil {
+		return 0, fmt.Errorf("cupoAcompanantesTx %s", err)
+	}
+
+	libres := cupoFamilia - usadosFamilia
+	if libres < 0 {
+		libres = 0
+	}
+	return a.acompanantes + libres, nil
+}
+
+func acompanantesTx(ctx context.Context, ej ejecutor, a anfitrion) (Acompanantes, error) {
+	cupo, err := cupoAcompanantesTx(ctx, ej, a)
+	if err != nil {
+		return Acompanantes{}, err
+	}
+
+	filas, err := ej.QueryContext(ctx, "SELECT nombre FROM Invitados WHERE id_anfitrion = ? ORDER BY id", a.id)
+	if err != nil {
+		return Acompanantes{}, fmt.Errorf("acompanantesTx %s", err)
+	}
+	defer filas.Close()
+
+	acompanantes := Acompanantes{Cupo: cupo, Nombres: []string{}}
+	for filas.Next() {
+		var nombre string
+		if err := filas.Scan(&nombre); err != nil {
+			return Acompanantes{}, fmt.Errorf("acompanantesTx %s", err)
+		}
+		acompanantes.Nombres = append(acompanantes.Nombres, nombre)
+	}
+
+	return acompanantes, filas.Err()
+}
+
+func (s *sqlStore) Acompanantes(ctx context.Context, idTextAnfitrion string) (Acompanantes, error) {
+	a, err := anfitrionTx(ctx, s.db, idTextAnfitrion)
+	if err != nil {
+		return Acompanantes{}, err
+	}
+	return acompanantesTx(ctx, s.db, a)
+}
+
+// NombrarAcompanantes conserva a los acompañantes cuyo nombre sigue en la
+// lista, borra a los demás y crea los nuevos en la familia del anfitrión, con
+// su misma asistencia.
+func (s *sqlStore) NombrarAcompanantes(ctx context.Context, idTextAnfitrion string, nombres []string) (Acompanantes, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return Acompanantes{}, fmt.Errorf("NombrarAcompanantes %s", err)
+	}
+	defer tx.Rollback()
+
+	a, err := anfitrionTx(ctx, tx, idTextAnfitrion)
+	if err != nil {
+		return Acompanantes{}, err
+	}
+	if a.esAcompanante {
+		return Acompanantes{}, errInvitadoEsAcompanante
+	}
+	if !a.asiste.Valid || !a.asiste.Bool {
+		return Acompanantes{}, errAnfitrionNoAsiste
+	}
+
+	cupo, err := cupoAcompanantesTx(ctx, tx, a)
+	if err != nil {
+		return Acompanantes{}, err
+	}
+	if len(nombres) > cupo {
+		return Acompanantes{Cupo: cupo}, errCupoAcompanantesExcedido
+	}
+
+	filas, err := tx.QueryContext(ctx, "SELECT id, nombre FROM Invitados WHERE id_anfitrion = ? ORDER BY id", a.id)
+	if err != nil {
+		return Acompanantes{}, fmt.Errorf("NombrarAcompanantes %s", err)
+	}
+	actuales := map[string][]int64{}
+	anteriores := []string{}
+	for filas.Next() {
+		var id int64
+		var nombre string
+		if err := filas.Scan(&id, &nombre); err != nil {
+			filas.Close()
+			return Acompanantes{}, fmt.Errorf("NombrarAcompanantes %s", err)
+		}
+		actuales[claveNombre(nombre)] = append(actuales[claveNombre(nombre)], id)
+		anteriores = append(anteriores, nombre)
+	}
+	filas.Close()
+
+	for _, nombre := range nombres {
+		if ids := actuales[claveNombre(nombre)]; len(ids) > 0 {
+			actuales[claveNombre(nombre)] = ids[1:]
+			continue
+		}
+		idText, err := generarIdTextDB(ctx, tx, "Invitados")
+		if err != nil {
+			return Acompanantes{}, err
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO Invitados (id_text, nombre, nombre_invitacion, asiste, id_familia, id_anfitrion) VALUES (?, ?, ?, ?, ?, ?)",
+			idText, nombre, nombre, a.asiste, a.idFamilia, a.id); err != nil {
+			return Acompanantes{}, fmt.Errorf("NombrarAcompanantes %s", err)
+		}
+	}
+
+	for _, ids := range actuales {
+		for _, id := range ids {
+			if err := borrarInvitadosTx(ctx, tx, "id = ?", id); err != nil {
+				return Acompanantes{}, err
+			}
+		}
+	}
+
+	acompanantes, err := acompanantesTx(ctx, tx, a)
+	if err != nil {
+		return Acompanantes{}, err
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionAcompanantes, Invitado: idTextAnfitrion, Anterior: anteriores, Nuevo: acompanantes.Nombres}); err != nil {
+		return Acompanantes{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Acompanantes{}, fmt.Errorf("NombrarAcompanantes %s", err)
+	}
+
+	return acompanantes, nil
+}
+
+func (s *sqlStore) CambiarCupoInvitado(ctx context.Context, idText string, cupo int) (InvitadoResp, error) {
+	invitado, err := s.InvitadoPorId(ctx, idText)
+	if err != nil {
+		return InvitadoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarCupoInvitado %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET acompanantes = ? WHERE id_text = ?", cupo, idText); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarCupoInvitado %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCupo, Invitado: idText, Anterior: invitado.Cupo_acompanantes, Nuevo: cupo}); err != nil {
+		return InvitadoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarCupoInvitado %s", err)
+	}
+
+	return s.InvitadoPorId(ctx, idText)
+}
+
+func (s *sqlStore) CambiarCupoFamilia(ctx context.Context, idText string, cupo int) (FamiliasResp, error) {
+	familia, err := s.FamiliaPorId(ctx, idText)
+	if err != nil {
+		return FamiliasResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return FamiliasResp{}, fmt.Errorf("CambiarCupoFamilia %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Familias SET acompanantes = ? WHERE id_text = ?", cupo, idText); err != nil {
+		return FamiliasResp{}, fmt.Errorf("CambiarCupoFamilia %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCupo, Familia: idText, Anterior: familia.Cupo_acompanantes, Nuevo: cupo}); err != nil {
+		return FamiliasResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return FamiliasResp{}, fmt.Errorf("CambiarCupoFamilia %s", err)
+	}
+
+	return s.FamiliaPorId(ctx, idText)
+}
+
+func (s *sqlStore) CambiarProrroga(ctx context.Context, idText string, hasta *time.Time) (InvitadoResp, error) {
+	invitado, err := s.InvitadoPorId(ctx, idText)
+	if err != nil {
+		return InvitadoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarProrroga %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET prorroga_rsvp = ? WHERE id_text = ?", fechaEvento(hasta), idText); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarProrroga %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionProrroga, Invitado: idText, Anterior: invitado.Prorroga, Nuevo: hasta}); err != nil {
+		return InvitadoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarProrroga %s", err)
+	}
+
+	return s.InvitadoPorId(ctx, idText)
+}
+
+func (s *sqlStore) CambiarMesa(ctx context.Context, idText string, mesa string) (InvitadoResp, error) {
+	invitado, err := s.InvitadoPorId(ctx, idText)
+	if err != nil {
+		return InvitadoResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarMesa %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET mesa = ? WHERE id_text = ?", mesa, idText); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarMesa %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionMesa, Invitado: idText, Anterior: invitado.Mesa, Nuevo: mesa}); err != nil {
+		return InvitadoResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return InvitadoResp{}, fmt.Errorf("CambiarMesa %s", err)
+	}
+
+	return s.InvitadoPorId(ctx, idText)
+}
+
+const columnasPlatillo = "p.id, p.id_text, p.nombre, p.descripcion, p.orden"
+
+func escanearPlatillo(fila interface{ Scan(...any) error }) (PlatilloResp, error) {
+	var platillo PlatilloResp
+	err := fila.Scan(&platillo.Id, &platillo.Id_text, &platillo.Nombre, &platillo.Descripcion, &platillo.Orden)
+	return platillo, err
+}
+
+func idPlatillo(ctx context.Context, ej ejecutor, idText string) (int64, error) {
+	var id int64
+	err := ej.QueryRowContext(ctx, "SELECT id FROM Platillos WHERE id_text = ?", idText).Scan(&id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, errPlatilloNoEncontrado
+	}
+	if err != nil {
+		return 0, fmt.Errorf("idPlatillo %s", err)
+	}
+	return id, nil
+}
+
+// Las restricciones se guardan en una sola columna, separadas por comas; las
+// etiquetas no pueden llevar comas.
+func unirRestricciones(restricciones []string) string {
+	return strings.Join(restricciones, ",")
+}
+
+func separarRestricciones(columna string) []string {
+	if columna == "" {
+		return []string{}
+	}
+	return strings.Split(columna, ",")
+}
+
+func (s *sqlStore) ListarPlatillos(ctx context.Context) ([]PlatilloResp, error) {
+	filas, err := s.db.QueryContext(ctx, "SELECT "+columnasPlatillo+" FROM Platillos p ORDER BY p.orden, p.id")
+	if err != nil {
+		return nil, fmt.Errorf("ListarPlatillos %s", err)
+	}
+	defer filas.Close()
+
+	platillos := []PlatilloResp{}
+	for filas.Next() {
+		platillo, err := escanearPlatillo(filas)
+		if err != nil {
+			return nil, fmt.Errorf("ListarPlatillos %s", err)
+		}
+		platillos = append(platillos, platillo)
+	}
+
+	return platillos, filas.Err()
+}
+
+func (s *sqlStore) PlatilloPorId(ctx context.Context, idText string) (PlatilloResp, error) {
+	platillo, err := escanearPlatillo(s.db.QueryRowContext(ctx, "SELECT "+columnasPlatillo+" FROM Platillos p WHERE p.id_text = ?", idText))
+	if errors.Is(err, sql.ErrNoRows) {
+		return platillo, errPlatilloNoEncontrado
+	}
+	if err != nil {
+		return platillo, fmt.Errorf("PlatilloPorId %s", err)
+	}
+
+	return platillo, nil
+}
+
+func (s *sqlStore) CrearPlatillo(ctx context.Context, cmd PlatilloCommand) (PlatilloResp, error) {
+	idText, err := generarIdTextDB(ctx, s.db, "Platillos")
+	if err != nil {
+		return PlatilloResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return PlatilloResp{}, fmt.Errorf("CrearPlatillo %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "INSERT INTO Platillos (id_text, nombre, descripcion, orden) VALUES (?, ?, ?, ?)",
+		idText, cmd.Nombre, cmd.Descripcion, cmd.Orden); err != nil {
+		return PlatilloResp{}, fmt.Errorf("CrearPlatillo %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionCrearPlatillo, Recurso: recursoPlatillo, Id_recurso: idText, Nuevo: cmd}); err != nil {
+		return PlatilloResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return PlatilloResp{}, fmt.Errorf("CrearPlatillo %s", err)
+	}
+
+	return s.PlatilloPorId(ctx, idText)
+}
+
+func (s *sqlStore) ReemplazarPlatillo(ctx context.Context, idText string, cmd PlatilloCommand) (PlatilloResp, error) {
+	platillo, err := s.PlatilloPorId(ctx, idText)
+	if err != nil {
+		return PlatilloResp{}, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return PlatilloResp{}, fmt.Errorf("ReemplazarPlatillo %s", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Platillos SET nombre = ?, descripcion = ?, orden = ? WHERE id = ?",
+		cmd.Nombre, cmd.Descripcion, cmd.Orden, platillo.Id); err != nil {
+		return PlatilloResp{}, fmt.Errorf("ReemplazarPlatillo %s", err)
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEditarPlatillo, Recurso: recursoPlatillo, Id_recurso: idText, Anterior: platilloAuditado(platillo), Nuevo: cmd}); err != nil {
+		return PlatilloResp{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return PlatilloResp{}, fmt.Errorf("ReemplazarPlatillo %s", err)
+	}
+
+	return s.PlatilloPorId(ctx, idText)
+}
+
+func (s *sqlStore) EliminarPlatillo(ctx context.Context, idText string) error {
+	platillo, err := s.PlatilloPorId(ctx, idText)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("EliminarPlatillo %s", err)
+	}
+	defer tx.Rollback()
+
+	id := platillo.Id
+	filas, err := tx.QueryContext(ctx, "SELECT id_text FROM Invitados WHERE id_platillo = ?", id)
+	if err != nil {
+		return fmt.Errorf("EliminarPlatillo %s", err)
+	}
+	var invitados []string
+	for filas.Next() {
+		var idTextInvitado string
+		if err := filas.Scan(&idTextInvitado); err != nil {
+			filas.Close()
+			return fmt.Errorf("EliminarPlatillo %s", err)
+		}
+		invitados = append(invitados, idTextInvitado)
+	}
+	filas.Close()
+	if err := filas.Err(); err != nil {
+		return fmt.Errorf("EliminarPlatillo %s", err)
+	}
+
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionEliminarPlatillo, Recurso: recursoPlatillo, Id_recurso: idText, Anterior: platilloAuditado(platillo)}); err != nil {
+		return err
+	}
+	for _, idTextInvitado := range invitados {
+		anterior, err := menuDeInvitadoTx(ctx, tx, idTextInvitado)
+		if err != nil {
+			return err
+		}
+		nueva := anterior
+		nueva.Platillo = ""
+		if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionMenu, Invitado: idTextInvitado, Anterior: anterior, Nuevo: nueva}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_platillo = NULL WHERE id_platillo = ?", id); err != nil {
+		return fmt.Errorf("EliminarPlatillo %s", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Platillos WHERE id = ?", id); err != nil {
+		return fmt.Errorf("EliminarPlatillo %s", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("EliminarPlatillo %s", err)
+	}
+
+	return nil
+}
+
+func (s *sqlStore) MenuDeInvitado(ctx context.Context, idTextInvitado string) (EleccionMenu, error) {
+	return menuDeInvitadoTx(ctx, s.db, idTextInvitado)
+}
+
+func menuDeInvitadoTx(ctx context.Context, ej ejecutor, idTextInvitado string) (EleccionMenu, error) {
+	var eleccion EleccionMenu
+	var platillo sql.NullString
+	var restricciones string
+
+	err := ej.QueryRowContext(ctx, `SELECT p.id_text, i.restricciones, i.alergias
+		FROM Invitados i LEFT JOIN Platillos p ON p.id = i.id_platillo WHERE i.id_text = ?`, idTextInvitado).
+		Scan(&platillo, &restricciones, &eleccion.Alergias)
+	if errors.Is(err, sql.ErrNoRows) {
+		return eleccion, errInvitadoNoEncontrado
+	}
+	if err != nil {
+		return eleccion, fmt.Errorf("menuDeInvitadoTx %s", err)
+	}
+
+	eleccion.Platillo = platillo.String
+	eleccion.Restricciones = separarRestricciones(restricciones)
+	return eleccion, nil
+}
+
+func (s *sqlStore) ElegirMenu(ctx context.Context, idTextInvitado string, eleccion EleccionMenu) (EleccionMenu, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return EleccionMenu{}, fmt.Errorf("ElegirMenu %s", err)
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var asiste sql.NullBool
+	err = tx.QueryRowContext(ctx, "SELECT id, asiste FROM Invitados WHERE id_text = ?", idTextInvitado).Scan(&id, &asiste)
+	if errors.Is(err, sql.ErrNoRows) {
+		return EleccionMenu{}, errInvitadoNoEncontrado
+	}
+	if err != nil {
+		return EleccionMenu{}, fmt.Errorf("ElegirMenu %s", err)
+	}
+	if !asiste.Valid || !asiste.Bool {
+		return EleccionMenu{}, errMenuSinAsistencia
+	}
+
+	anterior, err := menuDeInvitadoTx(ctx, tx, idTextInvitado)
+	if err != nil {
+		return EleccionMenu{}, err
+	}
+
+	var platillo sql.NullInt64
+	if eleccion.Platillo != "" {
+		if platillo.Int64, err = idPlatillo(ctx, tx, eleccion.Platillo); err != nil {
+			return EleccionMenu{}, err
+		}
+		platillo.Valid = true
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE Invitados SET id_platillo = ?, restricciones = ?, alergias = ? WHERE id = ?",
+		platillo, unirRestricciones(eleccion.Restricciones), eleccion.Alergias, id); err != nil {
+		return EleccionMenu{}, fmt.Errorf("ElegirMenu %s", err)
+	}
+
+	nueva, err := menuDeInvitadoTx(ctx, tx, idTextInvitado)
+	if err != nil {
+		return EleccionMenu{}, err
+	}
+	if err := registrarCambioTx(ctx, tx, cambioAuditoria{Accion: accionMenu, Invitado: idTextInvitado, Anterior: anterior, Nuevo: nueva}); err != nil {
+		return EleccionMenu{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return EleccionMenu{}, fmt.Errorf("ElegirMenu %s", err)
+	}
+
+	return nueva, nil
+}
+
+func (s *sqlStore) MenusConfirmados(ctx context.Context) ([]menuConfirmado, error) {
+	filas, err := s.db.QueryContext(ctx, `SELECT i.nombre, COALESCE(NULLIF(i.mesa, ''), a.mesa, ''), p.id_text, p.nombre, i.restricciones, i.alergias
+		FROM Invitados i
+		LEFT JOIN Invitados a ON a.id = i.id_anfitrion
+		LEFT JOIN Platillos p ON p.id = i.id_platillo
+		WHERE i.asiste = 1 AND (
+			NOT EXISTS (SELECT 1 FROM InvitacionesEvento ie WHERE ie.id_invitado = COALESCE(i.id_anfitrion, i.id))
+			OR EXISTS (SELECT 1 FROM InvitacionesEvento ie INNER JOIN Eventos e ON e.id = ie.id_evento
+				WHERE ie.id_invitado = COALESCE(i.id_anfitrion, i.id) AND ie.asiste = 1 AND e.sin_menu = 0))
+		ORDER BY i.id`)
+	if err != nil {
+		return nil, fmt.Errorf("MenusConfirmados %s", err)
+	}
+	defer filas.Close()
+
+	menus := []menuConfirmado{}
+	for filas.Next() {
+		var menu menuConfirmado
+		var idPlatillo, platillo sql.NullString
+		var restricciones string
+		if err := filas.Scan(&menu.Nombre, &menu.Mesa, &idPlatillo, &platillo, &restricciones, &menu.Alergias); err != nil {
+			return nil, fmt.Errorf("MenusConfirmados %s", err)
+		}
+		menu.Id_platillo = idPlatillo.String
+		menu.Platillo = platillo.String
+		menu.Restricciones = separarRestricciones(restricciones)
+		menus = append(menus, menu)
+	}
+
+	return menus, filas.Err()
+}
+
+// cambioAuditoria es una entrada del historial antes de escribirla. Con
+// Invitado y sin Familia se guarda la familia que tiene el invitado en ese
+// momento. Anterior y Nuevo se guardan en JSON; nil es que no aplica.
+type cambioAuditoria struct {
+	Accion     string
+	Invitado   string
+	Familia    string
+	Recurso    string
+	Id_recurso string
+	Anterior   any
+	Nuevo      any
+}
+
+// registrarCambioTx agrega el cambio al historial con el autor de ctx. Se
+// llama dentro de la misma transacción que el cambio y, si borra, antes de
+// borrar.
+func registrarCambioTx(ctx context.Context, ej ejecutor, c cambioAuditoria) error {
+	destino := c.Invitado
+	if destino == "" {
+		destino = c.Familia
+	}
+	autor := autorDeContexto(ctx).para(destino)
+
+	anterior, err := valorAuditoria(c.Anterior)
+	if err != nil {
+		return fmt.Errorf("registrarCambioTx %s", err)
+	}
+	nuevo, err := valorAuditoria(c.Nuevo)
+	if err != nil {
+		return fmt.Errorf("registrarCambioTx %s", err)
+	}
+
+	familia := sql.NullString{String: c.Familia, Valid: c.Familia != ""}
+	if !familia.Valid && c.Invitado != "" {
+		err := ej.QueryRowContext(ctx, "SELECT f.id_text FROM Invitados i INNER JOIN Familias f ON f.id = i.id_familia WHERE i.id_text = ?", c.Invitado).Scan(&familia)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("registrarCambioTx %s", err)
+		}
+	}
+
+	if _, err := ej.ExecContext(ctx, `INSERT INTO Auditoria (fecha, accion, id_invitado, id_familia, recurso, id_recurso, autor_tipo, autor, huella, anterior, nuevo, ip, user_agent)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().Unix(), c.Accion, sql.NullString{String: c.Invitado, Valid: c.Invitado != ""}, familia,
+		sql.NullString{String: c.Recurso, Valid: c.Recurso != ""}, sql.NullString{String: c.Id_recurso, Valid: c.Id_recurso != ""},
+		autor.Tipo, autor.Nombre, autor.Huella, anterior, nuevo, autor.Ip, autor.User_agent); err != nil {
+		return fmt.Errorf("registrarCambioTx %s", err)
+	}
+	return nil
+}
+
+func valorAuditoria(valor any) (sql.NullString, error) {
+	if valor == nil {
+		return sql.NullString{}, nil
+	}
+	texto, err := json.Marshal(valor)
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	return sql.NullString{String: string(texto), Valid: true}, nil
+}
+
+// asisteAuditada convierte una asistencia de la base al valor del historial.
+func asisteAuditada(asiste sql.NullBool) *bool {
+	if !asiste.Valid {
+		return nil
+	}
+	return &asiste.Bool
+}
+
+// textoAuditado convierte una columna de texto opcional al valor del historial.
+func textoAuditado(texto sql.NullString) *string {
+	if !texto.Valid {
+		return nil
+	}
+	return &texto.String
+}
+
+// eliminarInvitadosTx borra a los invitados que cumplen donde, una condición
+// sobre Invitados i, y a sus acompañantes, que solo venían con ellos. Antes de
+// borrarlos los agrega al historial.
+func eliminarInvitadosTx(ctx context.Context, ej ejecutor, donde string, args ...any) error {
+	ids, err := idsInvitadosTx(ctx, ej, "SELECT i.id FROM Invitados i WHERE "+donde+" ORDER BY i.id", args...)
+	if err != nil {
+		return err
+	}
+	vistos := map[int64]bool{}
+	for _, id := range ids {
+		vistos[id] = true
+	}
+	for _, id := range ids {
+		acompanantes, err := idsInvitadosTx(ctx, ej, "SELECT id FROM Invitados WHERE id_anfitrion = ? ORDER BY id", id)
+		if err != nil {
+			return err
+		}
+		for _, a := range acompanantes {
+			if !vistos[a] {
+				vistos[a] = true
+				ids = append(ids, a)
+			}
+		}
+	}
+
+	for _, id := range ids {
+		if err := registrarEliminadosTx(ctx, ej, consultaInvitados+" WHERE i.id = ?", id); err != nil {
+			return err
+		}
+	}
+	for _, id := range ids {
+		if err := borrarInvitadosTx(ctx, ej, "id = ?", id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func idsInvitadosTx(ctx context.Context, ej ejecutor, consulta string, args ...any) ([]int64, error) {
+	filas, err := ej.QueryContext(ctx, consulta, args...)
+	if err != nil {
+		return nil, fmt.Errorf("idsInvitadosTx %s", err)
+	}
+	defer filas.Close()
+
+	var ids []int64
+	for filas.Next() {
+		var id int64
+		if err := filas.Scan(&id); err != nil {
+			return nil, fmt.Errorf("idsInvitadosTx %s", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, filas.Err()
+}
+
+// borrarInvitadosTx borra a los invitados que cumplen donde, una condición
+// sobre Invitados, con lo que cuelga de ellos: sus invitaciones y respuestas
+// a eventos y los tokens de su enlace. La elección del menú está en la misma
+// fila.
+func borrarInvitadosTx(ctx context.Context, ej ejecutor, donde string, args ...any) error {
+	seleccion := "SELECT id FROM Invitados WHERE " + donde
+	if _, err := ej.ExecContext(ctx, "DELETE FROM InvitacionesEvento WHERE id_invitado IN ("+seleccion+")", args...); err != nil {
+		return fmt.Errorf("borrarInvitadosTx %s", err)
+	}
+	if _, err := ej.ExecContext(ctx, "DELETE FROM TokensInvitacion WHERE tipo = ? AND id_destino IN ("+seleccion+")", append([]any{invitacionInvitado}, args...)...); err != nil {
+		return fmt.Errorf("borrarInvitadosTx %s", err)
+	}
+	if _, err := ej.ExecContext(ctx, "DELETE FROM Invitados WHERE "+donde, args...); err != nil {
+		return fmt.Errorf("borrarInvitadosTx %s", err)
+	}
+	return nil
+}
+
+// registrarEliminadosTx agrega al historial a los invitados de la consulta,
+// que se van a borrar.
+func registrarEliminadosTx(ctx context.Context, ej ejecutor, consulta string, args ...any) error {
+	filas, err := ej.QueryContext(ctx, consulta, args...)
+	if err != nil {
+		return fmt.Errorf("registrarEliminadosTx %s", err)
+	}
+	var eliminados []InvitadoResp
+	for filas.Next() {
+		invitado, err := escanearInvitado(filas)
+		if err != nil {
+			filas.Close()
+			return fmt.Errorf("registrarEliminadosTx %s", err)
+		}
+		eliminados = append(eliminados, invitado)
+	}
+	filas.Close()
+	if err := filas.Err(); err != nil {
+		return fmt.Errorf("registrarEliminadosTx %s", err)
+	}
+
+	for _, invitado := range eliminados {
+		if err := registrarCambioTx(ctx, ej, cambioAuditoria{Accion: accionEliminarInvitado, Invitado: invitado.Id_text, Anterior: invitadoAuditado(invitado)}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// familiaAuditada es el valor de FamiliasCommand que se guarda en el historial.
+func familiaAuditada(familia FamiliasResp) FamiliasCommand {
+	return FamiliasCommand{Nombre: familia.Nombre, Miembro_principal: familia.Miembro_principal, Nombre_invitacion: familia.Nombre_invitacion}
+}
+
+// eventoAuditado es el valor de EventoCommand que se guarda en el historial.
+func eventoAuditado(evento EventoResp) EventoCommand {
+	return EventoCommand{Nombre: evento.Nombre, Lugar: evento.Lugar, Fecha: evento.Fecha, Orden: evento.Orden, Limite_rsvp: evento.Limite_rsvp, Sin_menu: evento.Sin_menu}
+}
+
+// platilloAuditado es el valor de PlatilloCommand que se guarda en el historial.
+func platilloAuditado(platillo PlatilloResp) PlatilloCommand {
+	return PlatilloCommand{Nombre: platillo.Nombre, Descripcion: platillo.Descripcion, Orden: platillo.Orden}
+}
+
+// tokenApiAuditado es el valor de un token de API en el historial: su dueño y
+// su nombre, nunca el token.
+type tokenApiAuditado struct {
+	Usuario int64  `json:"usuario"`
+	Nombre  string `json:"nombre"`
+}
+
+// invitadoAuditado es el valor de InvitadoCommand que se guarda en el historial.
+func invitadoAuditado(invitado InvitadoResp) InvitadoCommand {
+	return InvitadoCommand{Nombre: invitado.Nombre, Nombre_invitacion: invitado.Nombre_invitacion, Asiste: asisteAuditada(invitado.Asiste)}
+}
+
+const columnasAuditoria = "id, fecha, accion, id_invitado, id_familia, recurso, id_recurso, autor_tipo, autor, huella, anterior, nuevo, ip, user_agent"
+
+func (s *sqlStore) historial(ctx context.Context, donde string, args ...any) ([]EntradaAuditoria, error) {
+	filas, err := s.db.QueryContext(ctx, "SELECT "+columnasAuditoria+" FROM Auditoria WHERE "+donde+" ORDER BY fecha, id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("historial %s", err)
+	}
+	defer filas.Close()
+
+	historial := []EntradaAuditoria{}
+	for filas.Next() {
+		var entrada EntradaAuditoria
+		var fecha int64
+		var invitado, familia, recurso, idRecurso, anterior, nuevo sql.NullString
+		if err := filas.Scan(&entrada.Id, &fecha, &entrada.Accion, &invitado, &familia, &recurso, &idRecurso,
+			&entrada.Autor.Tipo, &entrada.Autor.Nombre, &entrada.Autor.Huella, &anterior, &nuevo,
+			&entrada.Autor.Ip, &entrada.Autor.User_agent); err != nil {
+			return nil, fmt.Errorf("historial %s", err)
+		}
+		entrada.Fecha = time.Unix(fecha, 0).UTC()
+		entrada.Invitado, entrada.Familia = invitado.String, familia.String
+		entrada.Recurso, entrada.Id_recurso = recurso.String, idRecurso.String
+		if anterior.Valid {
+			entrada.Anterior = json.RawMessage(anterior.String)
+		}
+		if nuevo.Valid {
+			entrada.Nuevo = json.RawMessage(nuevo.String)
+		}
+		historial = append(historial, entrada)
+	}
+
+	return historial, filas.Err()
+}
+
+func (s *sqlStore) HistorialInvitado(ctx context.Context, idText string) ([]EntradaAuditoria, error) {
+	return s.historial(ctx, "id_invitado = ?", idText)
+}
+
+func (s *sqlStore) HistorialFamilia(ctx context.Context, idText string) ([]EntradaAuditoria, error) {
+	return s.historial(ctx, "id_familia = ?", idText)
+}
+
+func (s *sqlStore) HistorialRecurso(ctx context.Context, recurso string, idText string) ([]EntradaAuditoria, error) {
+	return s.historial(ctx, "recurso = ? AND id_recurso = ?", recurso, idText)
+}
+
+func (s *sqlStore) HistorialAdmin(ctx context.Context) ([]EntradaAuditoria, error) {
+	return s.historial(ctx, "recurso IN (?, ?)", recursoUsuario, recursoTokenApi)
+}
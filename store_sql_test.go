@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// storePrueba abre una base SQLite nueva con todas las migraciones.
+func storePrueba(t *testing.T) *sqlStore {
+	t.Helper()
+
+	db, err := abrirSQLite(filepath.Join(t.TempDir(), "boda.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if _, err := migrarArriba(context.Background(), db, driverSQLite); err != nil {
+		t.Fatal(err)
+	}
+	return nuevoSqlStore(db)
+}
+
+// acompanantePrueba crea a ana, que confirma y nombra a beto como su
+// acompañante, y devuelve los id_text de los dos.
+func acompanantePrueba(t *testing.T, store *sqlStore) (string, string) {
+	t.Helper()
+	ctx := context.Background()
+
+	ana, err := store.CrearInvitado(ctx, InvitadoCommand{Nombre: "Ana", Nombre_invitacion: "Ana"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CambiarCupoInvitado(ctx, ana.Id_text, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.RegistrarAsistencia(ctx, ana.Id_text, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.NombrarAcompanantes(ctx, ana.Id_text, []string{"Beto"}); err != nil {
+		t.Fatal(err)
+	}
+
+	invitados, err := store.ListarInvitados(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, i := range invitados {
+		if i.Anfitrion != nil {
+			return ana.Id_text, i.Id_text
+		}
+	}
+	t.Fatal("no se creó el acompañante")
+	return "", ""
+}
+
+func TestActualizarAsistenciaLote(t *testing.T) {
+	ctx := context.Background()
+
+	casos := []struct {
+		nombre string
+		// lista usa "ana" y "beto" por los invitados creados; el resto son
+		// id_text desconocidos.
+		lista      []Asistencia
+		err        error
+		quiereAna  *bool
+		quiereBeto *bool
+	}{
+		{"aplica toda la lista", []Asistencia{{"ana", true}, {"beto", false}}, nil, ptrBool(true), ptrBool(false)},
+		{"un desconocido revierte todo", []Asistencia{{"ana", true}, {"desconocido", true}, {"beto", false}}, errAsistenciaLoteIncompleta, nil, nil},
+		{"desconocido al final", []Asistencia{{"ana", false}, {"beto", true}, {"desconocido", true}}, errAsistenciaLoteIncompleta, nil, nil},
+		{"lista vacía", nil, nil, nil, nil},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			store := storePrueba(t)
+			ids := map[string]string{}
+			for _, nombre := range []string{"ana", "beto"} {
+				invitado, err := store.CrearInvitado(ctx, InvitadoCommand{Nombre: nombre, Nombre_invitacion: nombre})
+				if err != nil {
+					t.Fatal(err)
+				}
+				ids[nombre] = invitado.Id_text
+			}
+
+			lista := make([]Asistencia, len(c.lista))
+			for i, a := range c.lista {
+				lista[i] = a
+				if id, ok := ids[a.Id_text]; ok {
+					lista[i].Id_text = id
+				}
+			}
+
+			resultados, err := store.ActualizarAsistenciaLote(ctx, lista)
+			if !errors.Is(err, c.err) {
+				t.Fatalf("ActualizarAsistenciaLote() error = %v, se esperaba %v", err, c.err)
+			}
+			if len(resultados) != len(lista) {
+				t.Errorf("ActualizarAsistenciaLote() devolvió %v resultados, se esperaban %v", len(resultados), len(lista))
+			}
+
+			for nombre, quiere := range map[string]*bool{"ana": c.quiereAna, "beto": c.quiereBeto} {
+				invitado, err := store.InvitadoPorId(ctx, ids[nombre])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if invitado.Asiste.Valid != (quiere != nil) || (quiere != nil && invitado.Asiste.Bool != *quiere) {
+					t.Errorf("asiste de %s = %+v, se esperaba %v", nombre, invitado.Asiste, quiere)
+				}
+			}
+		})
+	}
+}
+
+func ptrBool(b bool) *bool {
+	return &b
+}
+
+func TestInvitadoAnfitrionJSON(t *testing.T) {
+	ctx := context.Background()
+	store := storePrueba(t)
+	anfitrion, acompanante := acompanantePrueba(t, store)
+
+	casos := []struct {
+		idText string
+		quiere string
+	}{
+		{anfitrion, `"Anfitrion":null`},
+		{acompanante, `"Anfitrion":"` + anfitrion + `"`},
+	}
+
+	for _, c := range casos {
+		invitado, err := store.InvitadoPorId(ctx, c.idText)
+		if err != nil {
+			t.Fatal(err)
+		}
+		datos, err := json.Marshal(invitado)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(datos), c.quiere) {
+			t.Errorf("json de %s = %s, se esperaba %s", c.idText, datos, c.quiere)
+		}
+	}
+}
+
+func TestGuardarInvitadoConEventos(t *testing.T) {
+	ctx := context.Background()
+
+	casos := []struct {
+		nombre string
+		// respuestas son las que da ana antes de editarla, por evento.
+		respuestas map[string]bool
+		modificar  bool
+		asiste     *bool
+		quiere     map[string]*bool
+		// quiereAuditadas es cuántas respuestas a eventos debe auditar la
+		// edición.
+		quiereAuditadas int
+	}{
+		{"renombrar tras responder un evento", map[string]bool{"civil": true}, true, nil, map[string]*bool{"civil": ptrBool(true), "banquete": nil}, 0},
+		{"renombrar tras aceptar uno y rechazar otro", map[string]bool{"civil": true, "banquete": false}, true, nil, map[string]*bool{"civil": ptrBool(true), "banquete": ptrBool(false)}, 0},
+		{"reemplazar con el mismo resumen", map[string]bool{"civil": true, "banquete": false}, false, ptrBool(true), map[string]*bool{"civil": ptrBool(true), "banquete": ptrBool(false)}, 0},
+		{"reemplazar con otra asistencia", map[string]bool{"civil": true, "banquete": false}, false, ptrBool(false), map[string]*bool{"civil": ptrBool(false), "banquete": ptrBool(false)}, 1},
+		{"modificar enviando el mismo resumen", map[string]bool{"civil": true}, true, ptrBool(true), map[string]*bool{"civil": ptrBool(true), "banquete": nil}, 0},
+		{"modificar enviando otra asistencia", map[string]bool{"civil": true}, true, ptrBool(false), map[string]*bool{"civil": ptrBool(false), "banquete": ptrBool(false)}, 2},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			store := storePrueba(t)
+			ana, err := store.CrearInvitado(ctx, InvitadoCommand{Nombre: "Ana", Nombre_invitacion: "Ana"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			eventos := map[string]string{}
+			for i, nombre := range []string{"civil", "banquete"} {
+				evento, err := store.CrearEvento(ctx, EventoCommand{Nombre: nombre, Orden: i})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.InvitarAEvento(ctx, evento.Id_text, ana.Id_text); err != nil {
+					t.Fatal(err)
+				}
+				eventos[evento.Id_text] = nombre
+			}
+			for idEvento, nombre := range eventos {
+				if asiste, ok := c.respuestas[nombre]; ok {
+					if _, err := store.RegistrarAsistenciaEvento(ctx, ana.Id_text, idEvento, asiste); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			antes, err := store.HistorialInvitado(ctx, ana.Id_text)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			guardar := store.ReemplazarInvitado
+			if c.modificar {
+				guardar = store.ModificarInvitado
+			}
+			if _, err := guardar(ctx, ana.Id_text, InvitadoCommand{Nombre: "Ana María", Nombre_invitacion: "Ana", Asiste: c.asiste}); err != nil {
+				t.Fatal(err)
+			}
+
+			respuestas, err := store.EventosDeInvitado(ctx, ana.Id_text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, r := range respuestas {
+				quiere := c.quiere[eventos[r.Evento.Id_text]]
+				if (r.Asiste == nil) != (quiere == nil) || (quiere != nil && *r.Asiste != *quiere) {
+					t.Errorf("respuesta a %s = %v, se esperaba %v", eventos[r.Evento.Id_text], r.Asiste, quiere)
+				}
+			}
+
+			despues, err := store.HistorialInvitado(ctx, ana.Id_text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			auditadas := 0
+			for _, entrada := range despues[len(antes):] {
+				if entrada.Accion == accionAsistenciaEvento {
+					auditadas++
+				}
+			}
+			if auditadas != c.quiereAuditadas {
+				t.Errorf("la edición auditó %v respuestas a eventos, se esperaban %v", auditadas, c.quiereAuditadas)
+			}
+		})
+	}
+}
+
+func TestMenusConfirmadosSinMenu(t *testing.T) {
+	ctx := context.Background()
+
+	casos := []struct {
+		nombre string
+		// eventos son los eventos a los que se invita a ana con su respuesta;
+		// el civil es sin_menu y nil es que no respondió.
+		eventos map[string]*bool
+		quiere  []string
+	}{
+		{"sin eventos", nil, []string{"Ana", "Beto"}},
+		{"aceptó el banquete", map[string]*bool{"civil": ptrBool(true), "banquete": ptrBool(true)}, []string{"Ana", "Beto"}},
+		{"aceptó solo el civil", map[string]*bool{"civil": ptrBool(true), "banquete": ptrBool(false)}, nil},
+		{"banquete sin responder", map[string]*bool{"civil": ptrBool(true), "banquete": nil}, nil},
+		{"invitada solo al civil", map[string]*bool{"civil": ptrBool(true)}, nil},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nombre, func(t *testing.T) {
+			store := storePrueba(t)
+			ana, _ := acompanantePrueba(t, store)
+
+			for nombre, asiste := range c.eventos {
+				evento, err := store.CrearEvento(ctx, EventoCommand{Nombre: nombre, Sin_menu: nombre == "civil"})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := store.InvitarAEvento(ctx, evento.Id_text, ana); err != nil {
+					t.Fatal(err)
+				}
+				if asiste != nil {
+					if _, err := store.RegistrarAsistenciaEvento(ctx, ana, evento.Id_text, *asiste); err != nil {
+						t.Fatal(err)
+					}
+				}
+			}
+
+			menus, err := store.MenusConfirmados(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var nombres []string
+			for _, m := range menus {
+				nombres = append(nombres, m.Nombre)
+			}
+			if strings.Join(nombres, ",") != strings.Join(c.quiere, ",") {
+				t.Errorf("MenusConfirmados() = %v, se esperaba %v", nombres, c.quiere)
+			}
+		})
+	}
+}
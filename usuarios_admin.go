@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const largoMinimoClave = 10
+
+// hashClaveFicticia se compara cuando el usuario no existe, para que el login
+// tarde lo mismo y no revele qué nombres están registrados.
+var hashClaveFicticia, _ = bcrypt.GenerateFromPassword([]byte("clave-ficticia-de-relleno"), bcrypt.DefaultCost)
+
+type UsuarioCommand struct {
+	Nombre string `json:"nombre"`
+	Clave  string `json:"clave"`
+	Rol    string `json:"rol"`
+}
+
+type LoginRequest struct {
+	Nombre string `json:"nombre"`
+	Clave  string `json:"clave"`
+}
+
+type TokenApiCommand struct {
+	Nombre string `json:"nombre"`
+}
+
+type TokenApiResp struct {
+	Id     int64  `json:"id"`
+	Nombre string `json:"nombre"`
+	Token  string `json:"token"`
+}
+
+func (cmd *UsuarioCommand) normalizar() {
+	cmd.Nombre = strings.TrimSpace(cmd.Nombre)
+	cmd.Rol = strings.ToLower(strings.TrimSpace(cmd.Rol))
+}
+
+func (cmd UsuarioCommand) validar() error {
+	if cmd.Nombre == "" {
+		return errors.New("el nombre es obligatorio")
+	}
+	if utf8.RuneCountInString(cmd.Nombre) > largoMaximoNombre {
+		return fmt.Errorf("el nombre no puede superar %v caracteres", largoMaximoNombre)
+	}
+	if utf8.RuneCountInString(cmd.Clave) < largoMinimoClave {
+		return fmt.Errorf("la clave debe tener al menos %v caracteres", largoMinimoClave)
+	}
+	// bcrypt ignora todo lo que pase de 72 bytes.
+	if len(cmd.Clave) > 72 {
+		return errors.New("la clave no puede superar 72 bytes")
+	}
+	if !rolValido(cmd.Rol) {
+		return fmt.Errorf("rol desconocido %q, usa %s", cmd.Rol, strings.Join(rolesLectura, ", "))
+	}
+	return nil
+}
+
+// crearUsuario valida el comando y guarda el usuario con su clave procesada
+// con bcrypt. Lo usan tanto la API como el subcomando `user add`.
+func crearUsuario(ctx context.Context, store UserStore, cmd UsuarioCommand) (Usuario, error) {
+	cmd.normalizar()
+	if err := cmd.validar(); err != nil {
+		return Usuario{}, entradaInvalida(err.Error())
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(cmd.Clave), bcrypt.DefaultCost)
+	if err != nil {
+		return Usuario{}, fmt.Errorf("crearUsuario %s", err)
+	}
+
+	return store.CrearUsuario(ctx, cmd.Nombre, string(hash), cmd.Rol)
+}
+
+// crearTokenApi genera un token nuevo para el usuario. El valor en claro solo
+// se devuelve aquí; en la base queda su hash.
+func crearTokenApi(ctx context.Context, store UserStore, idUsuario int64, nombre string) (TokenApiResp, error) {
+	nombre = strings.TrimSpace(nombre)
+	if nombre == "" {
+		return TokenApiResp{}, entradaInvalida("el nombre del token es obligatorio")
+	}
+
+	token, err := generarSecreto()
+	if err != nil {
+		return TokenApiResp{}, fmt.Errorf("crearTokenApi %s", err)
+	}
+
+	id, err := store.CrearTokenApi(ctx, idUsuario, nombre, hashSecreto(token))
+	if err != nil {
+		return TokenApiResp{}, err
+	}
+
+	return TokenApiResp{Id: id, Nombre: nombre, Token: token}, nil
+}
+
+func ponerCookieSesion(gc *gin.Context, valor string, maxAge int) {
+	// Con Lax el navegador no manda la cookie en peticiones que empiezan en
+	// otro sitio, así que el panel debe servirse desde el mismo sitio que el
+	// backend: el mismo dominio registrable, como panel.boda.com y
+	// api.boda.com. Dos subdominios de un sufijo público como fly.dev son
+	// sitios distintos. Los demás clientes usan tokens de API.
+	gc.SetSameSite(http.SameSiteLaxMode)
+	gc.SetCookie(cookieSesion, valor, maxAge, "/", "", true, true)
+}
+
+func (s *servidor) iniciarSesion(gc *gin.Context) {
+	var peticion LoginRequest
+
+	if err := gc.ShouldBindJSON(&peticion); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	ctx := gc.Request.Context()
+
+	usuario, hashClave, err := s.usuarios.UsuarioPorNombre(ctx, strings.TrimSpace(peticion.Nombre))
+	if errors.Is(err, errUsuarioNoEncontrado) {
+		bcrypt.CompareHashAndPassword(hashClaveFicticia, []byte(peticion.Clave))
+		s.responderError(gc, &errorApi{Tipo: tipoNoAutorizado, Mensaje: "Usuario o clave incorrectos"})
+		return
+	}
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	if bcrypt.CompareHashAndPassword([]byte(hashClave), []byte(peticion.Clave)) != nil {
+		s.responderError(gc, &errorApi{Tipo: tipoNoAutorizado, Mensaje: "Usuario o clave incorrectos"})
+		return
+	}
+
+	sesion, err := generarSecreto()
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+	if err := s.usuarios.CrearSesion(ctx, usuario.Id, hashSecreto(sesion), time.Now().Add(duracionSesion)); err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	ponerCookieSesion(gc, sesion, int(duracionSesion.Seconds()))
+	s.json(gc, http.StatusOK, usuario)
+}
+
+func (s *servidor) cerrarSesion(gc *gin.Context) {
+	if sesion, err := gc.Cookie(cookieSesion); err == nil && sesion != "" {
+		if err := s.usuarios.EliminarSesion(gc.Request.Context(), hashSecreto(sesion)); err != nil {
+			s.responderError(gc, err)
+			return
+		}
+	}
+
+	ponerCookieSesion(gc, "", -1)
+	gc.Status(http.StatusNoContent)
+}
+
+func (s *servidor) getUsuarioActual(gc *gin.Context) {
+	s.json(gc, http.StatusOK, usuarioActual(gc))
+}
+
+func (s *servidor) getUsuarios(gc *gin.Context) {
+	usuarios, err := s.usuarios.ListarUsuarios(gc.Request.Context())
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusOK, usuarios)
+}
+
+func (s *servidor) crearUsuario(gc *gin.Context) {
+	var cmd UsuarioCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	usuario, err := crearUsuario(gc.Request.Context(), s.usuarios, cmd)
+	if errors.Is(err, errUsuarioExistente) {
+		s.responderError(gc, conflicto(err.Error()))
+		return
+	}
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusCreated, usuario)
+}
+
+// crearTokenApiPropio crea un token de API con el mismo rol del usuario que lo
+// pide, pensado para scripts e integraciones que no pueden usar la cookie.
+func (s *servidor) crearTokenApiPropio(gc *gin.Context) {
+	var cmd TokenApiCommand
+
+	if err := gc.ShouldBindJSON(&cmd); err != nil {
+		s.responderError(gc, peticionIncorrecta(err))
+		return
+	}
+
+	usuario := usuarioActual(gc)
+	if usuario.Id == 0 {
+		s.responderError(gc, entradaInvalida("ADMINTOKEN no puede crear tokens de API, inicia sesión con un usuario"))
+		return
+	}
+
+	token, err := crearTokenApi(gc.Request.Context(), s.usuarios, usuario.Id, cmd.Nombre)
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	s.json(gc, http.StatusCreated, token)
+}
+
+func (s *servidor) revocarTokenApi(gc *gin.Context) {
+	id, err := strconv.ParseInt(gc.Param("id"), 10, 64)
+	if err != nil {
+		s.responderError(gc, entradaInvalida("El id del token es incorrecto"))
+		return
+	}
+
+	err = s.usuarios.RevocarTokenApi(gc.Request.Context(), usuarioActual(gc).Id, id)
+	if errors.Is(err, errTokenApiNoEncontrado) {
+		s.responderError(gc, noEncontrado(err.Error()))
+		return
+	}
+	if err != nil {
+		s.responderError(gc, err)
+		return
+	}
+
+	gc.Status(http.StatusNoContent)
+}